@@ -0,0 +1,173 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+	"github.com/spf13/cobra"
+)
+
+func NewArchiveCmd() *cobra.Command {
+	var (
+		store  = state.NewState()
+		logger = NewLogger()
+	)
+	var (
+		olderThan  string
+		keepLatest bool
+	)
+	cmd := &cobra.Command{
+		Use:   "archive",
+		Short: "Move old releases to yearly archive files",
+		Long: fmt.Sprintf(
+			"Move the oldest releases created before --older-than to yearly archive files in %s.\n"+
+				"Use 'verify --full' to verify the chain including the archived releases.", ArchiveDir,
+		),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := store.Import(FileName); err != nil {
+				return eris.Wrap(err, "cli: could not import state file")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			age, err := parseAge(olderThan)
+			if err != nil {
+				return err
+			}
+			clock, err := resolveClock()
+			if err != nil {
+				return err
+			}
+			cutoff := clock.Now().Add(-age)
+			var checkpoint *state.Checkpoint
+			err = mutate(store, "archive", func(store *state.State) error {
+				store.Clock = clock
+				latest := make(map[state.Hash]bool)
+				for _, v := range store.Releases {
+					latest[store.Latest(v.Kind).BlockHash] = true
+				}
+				c, err := store.Archive(ArchiveDir, func(_ int, r *state.Release) bool {
+					if keepLatest && latest[r.BlockHash] {
+						return false
+					}
+					return r.CreatedAt.Before(cutoff)
+				})
+				if err != nil {
+					return eris.Wrap(err, "cli: could not archive")
+				}
+				checkpoint = c
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if checkpoint == nil {
+				logger.OK("nothing to archive")
+				return nil
+			}
+			if err := save(store); err != nil {
+				return err
+			}
+			logger.OK(fmt.Sprintf(
+				"archived %d releases to %s, checkpoint %s",
+				len(checkpoint.Archived), strings.Join(checkpoint.Archives, ", "), checkpoint.Hash.Short(),
+			))
+			notify(logger, store.Events())
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&olderThan, "older-than", "", "365d", "Archive releases older than the given age (e.g. 720h, 90d)")
+	cmd.Flags().BoolVarP(&keepLatest, "keep-latest", "", true, "Never archive the latest release of a kind")
+	return cmd
+}
+
+func NewCheckpointCmd() *cobra.Command {
+	var (
+		store  = state.NewState()
+		logger = NewLogger()
+	)
+	return &cobra.Command{
+		Use:   "checkpoint",
+		Short: "Record a checkpoint for the current head release",
+		Long:  "Record a checkpoint for the current head release. Commands only re-hash the releases created after the latest checkpoint.",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := store.Import(FileName); err != nil {
+				return eris.Wrap(err, "cli: could not import state file")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			clock, err := resolveClock()
+			if err != nil {
+				return err
+			}
+			var checkpoint *state.Checkpoint
+			err = mutate(store, "checkpoint", func(store *state.State) error {
+				store.Clock = clock
+				c, err := store.Checkpoint("manual")
+				if err != nil {
+					return eris.Wrap(err, "cli: could not create checkpoint")
+				}
+				checkpoint = c
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if err := save(store); err != nil {
+				return err
+			}
+			logger.OK(fmt.Sprintf("checkpoint %s created at %s", checkpoint.Hash.Short(), checkpoint.Head.Short()))
+			return nil
+		},
+	}
+}
+
+func NewVerifyCmd() *cobra.Command {
+	var (
+		store  = state.NewState()
+		logger = NewLogger()
+	)
+	var (
+		full           bool
+		fromCheckpoint bool
+	)
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify the release chain",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if full && fromCheckpoint {
+				return eris.New("cli: --full and --from-checkpoint can not be combined")
+			}
+			if fromCheckpoint {
+				// checks the links of the whole chain and only re-hashes the releases down to the latest checkpoint
+				if err := store.ImportFromCheckpoint(FileName); err != nil {
+					return eris.Wrap(err, "cli: verification failed")
+				}
+				if c := store.LatestCheckpoint(); c != nil {
+					logger.OK(fmt.Sprintf("chain verified, releases below checkpoint %s were not re-hashed", c.Hash.Short()))
+					return nil
+				}
+				logger.OK("chain verified")
+				return nil
+			}
+			if err := store.Import(FileName); err != nil {
+				return eris.Wrap(err, "cli: verification failed")
+			}
+			if full {
+				if err := store.ValidateWithArchive(ArchiveDir); err != nil {
+					return eris.Wrap(err, "cli: verification failed")
+				}
+				logger.OK("full chain verified")
+				return nil
+			}
+			logger.OK("chain verified")
+			return nil
+		},
+	}
+	cmd.Flags().BoolVarP(&full, "full", "", false, "Re-hash every release including the archived ones")
+	cmd.Flags().BoolVarP(&fromCheckpoint, "from-checkpoint", "", false, "Only re-hash the releases down to the latest checkpoint. The checkpoint is not trusted, it only saves work")
+	return cmd
+}
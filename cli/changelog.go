@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+	"github.com/spf13/cobra"
+)
+
+func NewChangelogCmd() *cobra.Command {
+	var (
+		store = state.NewState()
+	)
+	var (
+		from string
+		to   string
+		kind string
+	)
+	cmd := &cobra.Command{
+		Use:   "changelog",
+		Short: "Render a markdown changelog for a range of releases",
+		Long: "Render a markdown changelog for the releases after --from up to and including --to.\n" +
+			"Service changes are derived from consecutive releases, or consecutive releases of the same kind if --kind is given.",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := store.Import(FileName); err != nil {
+				return eris.Wrap(err, "cli: could not import state file")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			start, end := 0, len(store.Releases)
+			if to != "" {
+				r, err := store.Resolve(to)
+				if err != nil {
+					return eris.Wrap(err, "cli: could not resolve --to")
+				}
+				start = store.IndexOf(r.BlockHash)
+			}
+			if from != "" {
+				r, err := store.Resolve(from)
+				if err != nil {
+					return eris.Wrap(err, "cli: could not resolve --from")
+				}
+				end = store.IndexOf(r.BlockHash)
+			}
+			if start > end {
+				return eris.New("cli: --from must be older than --to")
+			}
+			var filter state.ReleaseKind
+			if kind != "" {
+				k, err := state.NewReleaseKindFromString(kind)
+				if err != nil {
+					return eris.Wrap(err, "cli: invalid kind")
+				}
+				filter = k
+			}
+			writeChangelog(os.Stdout, store.Releases, start, end, filter)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&from, "from", "f", "", "Exclusive start of the range (kind, tag or hash). Defaults to the first release")
+	cmd.Flags().StringVarP(&to, "to", "t", "", "Inclusive end of the range (kind, tag or hash). Defaults to the latest release")
+	cmd.Flags().StringVarP(&kind, "kind", "k", "", "Only include releases of the given kind")
+	return cmd
+}
+
+// writeChangelog renders releases[start:end] as markdown.
+// Releases outside of the range are only used to compute the changes of the oldest release.
+func writeChangelog(w io.Writer, releases []*state.Release, start, end int, kind state.ReleaseKind) {
+	fmt.Fprintln(w, "# Changelog")
+	for i := start; i < end; i++ {
+		r := releases[i]
+		if kind != 0 && !r.Kind.Is(kind) {
+			continue
+		}
+		var prev state.VersionMap
+		for _, v := range releases[i+1:] {
+			if kind == 0 || v.Kind.Is(kind) {
+				prev = v.Versions
+				break
+			}
+		}
+		fmt.Fprintf(w, "\n## %s (%s)\n\n", r.Tag, r.CreatedAt.Format("2006-01-02"))
+		fmt.Fprintf(w, "Kind: `%s`, block: `%s`\n", r.Kind, r.BlockHash.Short())
+		if r.Notes != "" {
+			fmt.Fprintf(w, "\n%s\n", strings.TrimSpace(r.Notes))
+		}
+		changes := r.Versions.Diff(prev)
+		if len(changes) == 0 {
+			fmt.Fprintln(w, "\nNo service changes.")
+			continue
+		}
+		fmt.Fprintln(w, "\n| Service | From | To |")
+		fmt.Fprintln(w, "| ------- | ---- | -- |")
+		for _, c := range changes {
+			fmt.Fprintf(w, "| %s | %s | %s |\n", c.Service, changelogVersion(c.From, "added"), changelogVersion(c.To, "removed"))
+		}
+	}
+}
+
+func changelogVersion(v state.ServiceVersion, empty string) string {
+	if v.IsZero() {
+		return "_" + empty + "_"
+	}
+	return "`" + v.String() + "`"
+}
@@ -0,0 +1,28 @@
+package cli
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+)
+
+// SourceDateEpochEnv overrides the creation time of new releases.
+// It follows https://reproducible-builds.org/specs/source-date-epoch/.
+const SourceDateEpochEnv = "SOURCE_DATE_EPOCH"
+
+// resolveClock returns a fixed clock if SOURCE_DATE_EPOCH is set,
+// otherwise the system clock.
+func resolveClock() (state.Clock, error) {
+	v := os.Getenv(SourceDateEpochEnv)
+	if v == "" {
+		return state.SystemClock{}, nil
+	}
+	epoch, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return nil, eris.Wrapf(err, "cli: invalid %s %q", SourceDateEpochEnv, v)
+	}
+	return state.FixedClock(time.Unix(epoch, 0).UTC()), nil
+}
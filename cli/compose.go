@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hsblhsn/microstate/source"
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+	"github.com/spf13/cobra"
+)
+
+func NewComposeCmd() *cobra.Command {
+	var (
+		store  = state.NewState()
+		logger = NewLogger()
+	)
+	var (
+		file     string
+		mappings []string
+		dryRun   bool
+	)
+	cmd := &cobra.Command{
+		Use:   "compose <kind|tag|hash>",
+		Short: "Rewrite the image tags of a docker-compose file from a release",
+		Args:  cobra.ExactArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := store.Import(FileName); err != nil {
+				return eris.Wrap(err, "cli: could not import state file")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			r, err := store.Resolve(args[0])
+			if err != nil {
+				return eris.Wrap(err, "cli: could not find release")
+			}
+			mapping, err := source.ParseMapping(mappings)
+			if err != nil {
+				return eris.Wrap(err, "cli: invalid --map")
+			}
+			b, err := os.ReadFile(file)
+			if err != nil {
+				return eris.Wrap(err, "cli: could not read compose file")
+			}
+			out, changes, err := source.RewriteCompose(b, r.Versions, mapping, store.Registry.Rules())
+			if err != nil {
+				return eris.Wrap(err, "cli: could not rewrite compose file")
+			}
+			if dryRun {
+				fmt.Print(string(out))
+				return nil
+			}
+			if err := os.WriteFile(file, out, os.ModePerm); err != nil {
+				return eris.Wrap(err, "cli: could not write compose file")
+			}
+			for _, c := range changes {
+				logger.OK(fmt.Sprintf("%s: %s -> %s", c.Service, c.From, c.To))
+			}
+			logger.OK(fmt.Sprintf("%d images of %s set to %s", len(changes), file, r.String()))
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&file, "file", "f", source.DefaultComposeFile, "Compose file to rewrite")
+	cmd.Flags().StringArrayVarP(&mappings, "map", "", make([]string, 0),
+		"Map a compose service name to a service name. It accepts array of values. (e.g. --map api=user-service)",
+	)
+	cmd.Flags().BoolVarP(&dryRun, "dry-run", "", false, "Print the rewritten file instead of writing it")
+	return cmd
+}
@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/hsblhsn/microstate/cloudevent"
+	"github.com/hsblhsn/microstate/git"
+	"github.com/hsblhsn/microstate/hook"
+	"github.com/hsblhsn/microstate/state"
+	"github.com/hsblhsn/microstate/webhook"
+	"github.com/rotisserie/eris"
+)
+
+// Config holds the local settings of microstate.
+// Every integration is disabled unless it is configured.
+type Config struct {
+	Git      *GitConfig      `json:"git,omitempty"`
+	Webhooks *WebhooksConfig `json:"webhooks,omitempty"`
+	Events   *EventsConfig   `json:"events,omitempty"`
+	// Hooks run local commands before and after ledger changes.
+	Hooks []hook.Hook `json:"hooks,omitempty"`
+}
+
+// GitConfig configures the git integration.
+type GitConfig struct {
+	// Tag lists the release kinds that are tagged when they are published. e.g. ["ga"]
+	Tag []string `json:"tag,omitempty"`
+	// TagPrefix is the prefix of the service repository tags. Defaults to git.DefaultTagPrefix.
+	TagPrefix string `json:"tag_prefix,omitempty"`
+	// Repositories map service names to local clone paths.
+	Repositories map[string]string `json:"repositories,omitempty"`
+	// TagLedger tags the repository holding the state file with the release tag.
+	// Without the git store, published releases are only tagged by the tag command once the state file is committed.
+	TagLedger bool `json:"tag_ledger,omitempty"`
+	// Store commits every change of the state file to a branch.
+	Store *GitStoreConfig `json:"store,omitempty"`
+}
+
+// GitStoreConfig configures the git-backed store of the state file.
+type GitStoreConfig struct {
+	// Branch receiving the commits. Defaults to the checked out branch.
+	Branch string `json:"branch,omitempty"`
+	// Remote is fetched before and pushed after every change. Empty keeps the branch local.
+	Remote string `json:"remote,omitempty"`
+	// Retries is the number of times an operation is replayed when the branch moved.
+	Retries int `json:"retries,omitempty"`
+}
+
+// WebhooksConfig configures the webhook notifications of ledger events.
+type WebhooksConfig struct {
+	Endpoints []webhook.Endpoint `json:"endpoints"`
+	// Retries is the number of times a failed delivery is retried. Defaults to webhook.DefaultRetries.
+	Retries int `json:"retries,omitempty"`
+	// Log is the path of the delivery log. Defaults to DeliveryLogFileName.
+	Log string `json:"log,omitempty"`
+}
+
+// Dispatcher returns the dispatcher of the webhooks config.
+func (c *WebhooksConfig) Dispatcher() *webhook.Dispatcher {
+	d := webhook.NewDispatcher(c.Endpoints)
+	if c.Retries > 0 {
+		d.Retries = c.Retries
+	}
+	d.Log = c.Log
+	if d.Log == "" {
+		d.Log = DeliveryLogFileName
+	}
+	return d
+}
+
+// EventsConfig configures the CloudEvents stream of ledger events.
+type EventsConfig struct {
+	// File the events are appended to, one json line each. "-" writes to the standard output.
+	File string `json:"file"`
+	// Source is the CloudEvents source attribute. Defaults to cloudevent.DefaultSource.
+	Source string `json:"source,omitempty"`
+}
+
+// Writer returns the event stream writer of the events config.
+func (c *EventsConfig) Writer() *cloudevent.Writer {
+	return cloudevent.NewWriter(c.File, c.Source)
+}
+
+// loadConfig reads the config file.
+// It returns an empty config if the config file does not exist.
+func loadConfig() (*Config, error) {
+	cfg := new(Config)
+	b, err := os.ReadFile(ConfigFileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return nil, eris.Wrap(err, "cli: could not read config file")
+	}
+	if err := json.Unmarshal(b, cfg); err != nil {
+		return nil, eris.Wrap(err, "cli: could not parse config file")
+	}
+	for _, h := range cfg.Hooks {
+		if err := h.Validate(); err != nil {
+			return nil, eris.Wrap(err, "cli: invalid hook")
+		}
+	}
+	if cfg.Git != nil {
+		for _, v := range cfg.Git.Tag {
+			if _, err := state.NewReleaseKindFromString(v); err != nil {
+				return nil, eris.Wrapf(err, "cli: invalid release kind %q in git.tag", v)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+// runHooks runs the configured hooks of the phase for the events.
+func (c *Config) runHooks(phase hook.Phase, events []state.Event) error {
+	if len(c.Hooks) == 0 {
+		return nil
+	}
+	r := &hook.Runner{Hooks: c.Hooks}
+	return r.Run(phase, events)
+}
+
+// Tagger returns the tagger of the git config.
+// The service names of the repositories are normalized with the given rules.
+func (c *GitConfig) Tagger(rules state.Normalization) *git.Tagger {
+	t := &git.Tagger{
+		Repositories:  c.Repositories,
+		Normalization: rules,
+		Prefix:        c.TagPrefix,
+	}
+	if c.TagLedger {
+		t.Ledger = FileName
+	}
+	if c.Store != nil {
+		t.LedgerBranch = c.Store.Branch
+	}
+	return t
+}
+
+// Tags reports whether releases of the given kind are tagged when they are published.
+func (c *GitConfig) Tags(kind state.ReleaseKind) bool {
+	if c == nil {
+		return false
+	}
+	for _, v := range c.Tag {
+		if k, err := state.NewReleaseKindFromString(v); err == nil && k.Is(kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// Open opens the git-backed store of the state file.
+func (c *GitStoreConfig) Open() (*git.Store, error) {
+	store, err := git.NewStore(FileName, c.Branch, c.Remote)
+	if err != nil {
+		return nil, eris.Wrap(err, "cli: could not open git store")
+	}
+	store.Retries = c.Retries
+	return store, nil
+}
@@ -4,7 +4,7 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/Masterminds/semver/v3"
+	"github.com/hsblhsn/microstate/source"
 	"github.com/hsblhsn/microstate/state"
 	"github.com/rotisserie/eris"
 	"github.com/spf13/cobra"
@@ -14,14 +14,18 @@ func NewDevCmd() *cobra.Command {
 	var (
 		store  = state.NewState()
 		logger = NewLogger()
+		opts   = new(publishOptions)
 	)
 	var (
-		from     string
-		fromKind string
-		services []string
-		major    bool
-		minor    bool
-		patch    bool
+		from          string
+		fromKind      string
+		fromManifests string
+		fromCompose   string
+		mappings      []string
+		services      []string
+		major         bool
+		minor         bool
+		patch         bool
 	)
 	cmd := &cobra.Command{
 		Use: "dev",
@@ -32,83 +36,125 @@ func NewDevCmd() *cobra.Command {
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			versionMap := state.NewVersionMap()
-			for _, v := range services {
-				v = strings.TrimSpace(v)
-				parts := strings.SplitN(v, "@", 2)
-				if len(parts) < 2 {
-					return eris.New("invalid service: " + v)
-				}
-				versionMap.Set(parts[0], parts[1])
-			}
-			latestDev := store.Latest(state.ReleaseKindDev)
-			latestDevVersion, err := semver.NewVersion(latestDev.Tag)
+			releaseOpts, err := opts.releaseOptions()
 			if err != nil {
-				return eris.Wrap(err, "cli: could not parse latest dev release version")
+				return err
 			}
-			var nextTag string
-			{
-				var nextVersion semver.Version
-				if major {
-					nextVersion = latestDevVersion.IncMajor()
-				} else if minor {
-					nextVersion = latestDevVersion.IncMinor()
-				} else if patch {
-					nextVersion = latestDevVersion.IncPatch()
-				} else {
-					nextVersion = latestDevVersion.IncPatch()
-				}
-				nextVersion, err = nextVersion.SetPrerelease(state.ReleaseKindDev.String())
-				if err != nil {
-					return eris.Wrap(err, "cli: could not set prerelease info on version tag")
+			return mutate(store, "publish", func(store *state.State) error {
+				if err := opts.prepare(store); err != nil {
+					return err
 				}
-				nextTag = "v" + nextVersion.String()
-			}
-			if from != "" || fromKind != "" {
-				var fromRelease *state.Release
-				if from != "" {
-					hash, err := state.NewHash(from)
+				versionMap := state.NewVersionMap()
+				if fromManifests != "" || fromCompose != "" {
+					mapping, err := source.ParseMapping(mappings)
 					if err != nil {
-						return eris.Wrap(err, "cli: hash is not valid")
+						return eris.Wrap(err, "cli: invalid --map")
 					}
-					fromRelease, err = store.GetRelease(hash)
-					if err != nil {
-						return eris.Wrap(err, "cli: could not get release")
+					found := state.NewVersionMap()
+					if fromManifests != "" {
+						found, err = source.Kubernetes(fromManifests, mapping)
+						if err != nil {
+							return eris.Wrap(err, "cli: could not import kubernetes manifests")
+						}
+					}
+					if fromCompose != "" {
+						compose, err := source.Compose(fromCompose, mapping)
+						if err != nil {
+							return eris.Wrap(err, "cli: could not import compose file")
+						}
+						for k, v := range compose {
+							found[k] = v
+						}
+					}
+					for _, svc := range found.Services() {
+						store.Registry.Rules().Set(versionMap, svc, found[svc])
 					}
-				} else if fromKind != "" {
-					kind, err := state.NewReleaseKindFromString(fromKind)
+				}
+				for _, v := range services {
+					name, version, err := state.ParseService(v)
 					if err != nil {
-						return eris.Wrap(err, "cli: hash is not valid")
+						return eris.Wrap(err, "cli: invalid --service")
 					}
-					fromRelease = store.Latest(kind)
-				} else {
-					return eris.New("cli: --from or --from-kind must be specified")
+					store.Registry.Rules().Set(versionMap, name, version)
 				}
-				// replace original versions with new ones
-				for k, v := range versionMap {
-					fromRelease.Versions[k] = v
+				if unknown := store.Registry.Unknown(versionMap); len(unknown) != 0 {
+					return eris.Wrapf(state.ErrServiceUnknown, "cli: unknown services %s, register them with 'microstate services add'", strings.Join(unknown, ", "))
 				}
-				fromRelease.Tag = nextTag
-				fromRelease.Kind = state.ReleaseKindDev
-				if err := store.CreateRelease(fromRelease); err != nil {
-					return eris.Wrap(err, "cli: could not create release")
+				if err := store.Registry.ValidateVersions(versionMap); err != nil {
+					return eris.Wrap(err, "cli: invalid --service")
 				}
-			} else {
-				r, err := state.NewRelease(state.ReleaseKindDev, nextTag, versionMap)
+				// the empty bump increments the patch version, like --patch
+				var bump state.Bump
+				if major {
+					bump = state.BumpMajor
+				} else if minor {
+					bump = state.BumpMinor
+				} else if patch {
+					bump = state.BumpPatch
+				}
+				nextTag, err := store.NextDevTag(bump)
 				if err != nil {
-					return eris.Wrap(err, "cli: could not build release")
+					return eris.Wrap(err, "cli: could not determine next dev release tag")
 				}
-				if err := store.CreateRelease(r); err != nil {
-					return eris.Wrap(err, "cli: could not create release")
+				if from != "" || fromKind != "" {
+					var fromRelease *state.Release
+					if from != "" {
+						hash, err := state.NewHash(from)
+						if err != nil {
+							return eris.Wrap(err, "cli: hash is not valid")
+						}
+						fromRelease, err = store.GetRelease(hash)
+						if err != nil {
+							return eris.Wrap(err, "cli: could not get release")
+						}
+					} else if fromKind != "" {
+						kind, err := state.NewReleaseKindFromString(fromKind)
+						if err != nil {
+							return eris.Wrap(err, "cli: hash is not valid")
+						}
+						fromRelease = store.Latest(kind)
+					} else {
+						return eris.New("cli: --from or --from-kind must be specified")
+					}
+					// replace original versions with new ones
+					for k, v := range versionMap {
+						fromRelease.Versions[k] = v
+					}
+					fromRelease.Tag = nextTag
+					fromRelease.Kind = state.ReleaseKindDev
+					fromRelease.Annotations = nil
+					fromRelease.Notes = ""
+					if err := fromRelease.Apply(releaseOpts...); err != nil {
+						return eris.Wrap(err, "cli: could not build release")
+					}
+					if err := store.CreateRelease(fromRelease); err != nil {
+						return eris.Wrap(err, "cli: could not create release")
+					}
+				} else {
+					r, err := state.NewRelease(state.ReleaseKindDev, nextTag, versionMap)
+					if err != nil {
+						return eris.Wrap(err, "cli: could not build release")
+					}
+					if err := r.Apply(releaseOpts...); err != nil {
+						return eris.Wrap(err, "cli: could not build release")
+					}
+					if err := store.CreateRelease(r); err != nil {
+						return eris.Wrap(err, "cli: could not create release")
+					}
 				}
-			}
-			return nil
+				return nil
+			})
 		},
 		PostRunE: func(cmd *cobra.Command, args []string) error {
-			if err := store.Export(FileName); err != nil {
-				return eris.Wrap(err, "cli: could not export state file")
+			if err := save(store); err != nil {
+				return err
 			}
 			logger.OK(fmt.Sprintf("dev release created: %s", store.Latest(state.ReleaseKindDev).Tag))
+			for _, svc := range store.Registry.Missing(store.Latest(state.ReleaseKindDev).Versions) {
+				logger.Warn(fmt.Sprintf("registered service %s is missing from the release", svc))
+			}
+			notify(logger, store.Events())
+			autoTag(logger, store.Latest(state.ReleaseKindDev))
 			fmt.Print(store.Latest(state.ReleaseKindDev).Tag)
 			return nil
 		},
@@ -118,8 +164,14 @@ func NewDevCmd() *cobra.Command {
 	cmd.Flags().BoolVarP(&major, "major", "", false, "Major version upgrade")
 	cmd.Flags().BoolVarP(&minor, "minor", "", false, "Minor version upgrade")
 	cmd.Flags().BoolVarP(&patch, "patch", "", false, "Patch version upgrade")
+	cmd.Flags().StringVarP(&fromManifests, "from-manifests", "", "", "Read service images from the kubernetes manifests in the given directory")
+	cmd.Flags().StringVarP(&fromCompose, "from-compose", "", "", "Read service images from the given docker-compose file")
+	cmd.Flags().StringArrayVarP(&mappings, "map", "", make([]string, 0),
+		"Map a container or compose service name to a service name. It accepts array of values. (e.g. --map api=user-service --map user-api/istio-proxy=-)",
+	)
 	cmd.Flags().StringArrayVarP(&services, "service", "s", make([]string, 0),
-		"Service name and version. It accepts array of values. (e.g. --service serviceA@v1.0 --service serviceB@v1.0)",
+		"Service name and version. It accepts array of values. (e.g. --service serviceA@v1.0 --service serviceB:image=ghcr.io/acme/b@sha256:...,sha=4ca603f)",
 	)
+	opts.register(cmd)
 	return cmd
 }
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"github.com/hsblhsn/microstate/cloudevent"
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+	"github.com/spf13/cobra"
+)
+
+func NewEventsCmd() *cobra.Command {
+	var (
+		store = state.NewState()
+	)
+	var (
+		since  string
+		source string
+		output string
+	)
+	cmd := &cobra.Command{
+		Use:   "events",
+		Short: "Replay the release chain as CloudEvents, one json line each",
+		Long: "Replay the release chain as CloudEvents, oldest first.\n" +
+			"Consumers pass the block hash of the last event they processed with --since to sync incrementally.",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := store.Import(FileName); err != nil {
+				return eris.Wrap(err, "cli: could not import state file")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var hash state.Hash
+			if since != "" {
+				h, err := state.NewHash(since)
+				if err != nil {
+					return eris.Wrap(err, "cli: hash is not valid")
+				}
+				hash = h
+			}
+			events, err := store.Replay(hash)
+			if err != nil {
+				return eris.Wrap(err, "cli: could not replay the chain")
+			}
+			if source == "" {
+				cfg, err := loadConfig()
+				if err != nil {
+					return err
+				}
+				if cfg.Events != nil {
+					source = cfg.Events.Source
+				}
+			}
+			w := cloudevent.NewWriter(output, source)
+			if err := w.Write(events); err != nil {
+				return eris.Wrap(err, "cli: could not write events")
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&since, "since", "", "", "Replay the releases created after the release of the given block hash")
+	cmd.Flags().StringVarP(&source, "source", "", "", "CloudEvents source attribute. Defaults to the events source of the config file")
+	cmd.Flags().StringVarP(&output, "output", "o", cloudevent.Stdout, "Append the events to the given file instead of the standard output")
+	return cmd
+}
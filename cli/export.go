@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/hsblhsn/microstate/render"
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+	"github.com/spf13/cobra"
+)
+
+func NewExportCmd() *cobra.Command {
+	var (
+		store = state.NewState()
+	)
+	var (
+		format      string
+		keyTemplate string
+		output      string
+	)
+	formats := make([]string, 0, len(render.Formats))
+	for _, f := range render.Formats {
+		formats = append(formats, string(f))
+	}
+	cmd := &cobra.Command{
+		Use:   "export <kind|tag|hash>",
+		Short: "Export the service versions of a release as deployment configuration",
+		Long: "Export the service versions of a release as deployment configuration.\n\n" +
+			"Keys are rendered with Go templates, the service is available as {{.Service}},\n" +
+			"its fields as {{.Version}}, {{.Image}}, {{.SHA}}, {{.Chart}}, {{.Config}} and\n" +
+			"the parsed image as {{.Repository}}, {{.Tag}}, {{.Digest}}.\n" +
+			"Helper functions: env, snake, upper and lower.",
+		Example: "  microstate export ga --format helm-values --key '{{.Service}}.image.tag'\n" +
+			"  microstate export v1.2.0 --format dotenv --key '{{.Service | env}}_IMAGE_TAG'",
+		Args: cobra.ExactArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := store.Import(FileName); err != nil {
+				return eris.Wrap(err, "cli: could not import state file")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			r, err := store.Resolve(args[0])
+			if err != nil {
+				return eris.Wrap(err, "cli: could not find release")
+			}
+			var w io.Writer = os.Stdout
+			if output != "" {
+				f, err := os.Create(output)
+				if err != nil {
+					return eris.Wrap(err, "cli: could not create output file")
+				}
+				defer f.Close()
+				w = f
+			}
+			if err := render.Export(w, r, render.Format(format), keyTemplate); err != nil {
+				return eris.Wrap(err, "cli: could not export release")
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&format, "format", "f", string(render.FormatJSON), fmt.Sprintf("Output format. One of %s", strings.Join(formats, ", ")))
+	cmd.Flags().StringVarP(&keyTemplate, "key", "", "", "Key template. Defaults to a template per format")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Write to the given file instead of stdout")
+	return cmd
+}
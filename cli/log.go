@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+	"github.com/spf13/cobra"
+)
+
+func NewLogCmd() *cobra.Command {
+	var (
+		store = state.NewState()
+	)
+	var (
+		kind        string
+		annotations []string
+		limit       int
+	)
+	cmd := &cobra.Command{
+		Use:   "log",
+		Short: "Show the release history, newest first",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := store.Import(FileName); err != nil {
+				return eris.Wrap(err, "cli: could not import state file")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var filterKind state.ReleaseKind
+			if kind != "" {
+				k, err := state.NewReleaseKindFromString(kind)
+				if err != nil {
+					return eris.Wrap(err, "cli: invalid kind")
+				}
+				filterKind = k
+			}
+			filter := make(state.Annotations)
+			for _, v := range annotations {
+				key, value, err := state.ParseAnnotation(v)
+				if err != nil {
+					return eris.Wrap(err, "cli: could not parse annotation filter")
+				}
+				filter[key] = value
+			}
+			printed := 0
+			for _, r := range store.Releases {
+				if limit > 0 && printed >= limit {
+					break
+				}
+				if filterKind != 0 && !r.Kind.Is(filterKind) {
+					continue
+				}
+				if !r.Annotations.Match(filter) {
+					continue
+				}
+				fmt.Printf("%s %-32s %s\n", r.BlockHash.Short(), r.String(), r.CreatedAt.Format("2006-01-02 15:04:05"))
+				if r.Origin != nil {
+					fmt.Printf("          by %s\n", r.Origin)
+				}
+				for _, k := range r.Annotations.Keys() {
+					fmt.Printf("          %s=%s\n", k, r.Annotations[k])
+				}
+				printed++
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&kind, "kind", "k", "", "Only show releases of the given kind")
+	cmd.Flags().StringArrayVarP(&annotations, "annotation", "a", make([]string, 0),
+		"Only show releases having the annotation. It accepts array of values. (e.g. --annotation ticket=OPS-1)",
+	)
+	cmd.Flags().IntVarP(&limit, "limit", "n", 0, "Maximum number of releases to show")
+	return cmd
+}
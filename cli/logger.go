@@ -44,6 +44,10 @@ func (l *Logger) Error(v interface{}) {
 	fmt.Fprintf(l.l, "ERROR: %v\n", aurora.BrightRed(v))
 }
 
+func (l *Logger) Warn(v interface{}) {
+	fmt.Fprintf(l.l, "WARN: %v\n", aurora.BrightYellow(v))
+}
+
 func (l *Logger) OK(v interface{}) {
 	fmt.Fprintf(l.l, "OK: %v\n", aurora.BrightGreen(v))
 }
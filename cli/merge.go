@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hsblhsn/microstate/git"
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+	"github.com/spf13/cobra"
+)
+
+const mergeDriverName = "microstate"
+
+func NewMergeDriverCmd() *cobra.Command {
+	var (
+		logger = NewLogger()
+	)
+	var (
+		rechain []string
+		install bool
+	)
+	cmd := &cobra.Command{
+		Use:   "merge-driver <base> <ours> <theirs>",
+		Short: "Merge diverged state files, used as a git merge driver",
+		Long: "Merge diverged state files, used as a git merge driver.\n\n" +
+			"The releases both sides created since their common release are combined,\n" +
+			"the later ones are re-linked onto the other side if their kind may be re-hashed.\n" +
+			"The merged state is written to the <ours> file.\n\n" +
+			"Install it in the current repository with 'microstate merge-driver --install', which runs\n" +
+			"  git config merge." + mergeDriverName + ".driver 'microstate merge-driver %O %A %B'\n" +
+			"and adds '" + filepath.Base(FileName) + " merge=" + mergeDriverName + "' to .gitattributes.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if install {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.ExactArgs(3)(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if install {
+				return installMergeDriver(logger, rechain)
+			}
+			policy := state.MergePolicy{}
+			for _, v := range rechain {
+				kind, err := state.NewReleaseKindFromString(v)
+				if err != nil {
+					return eris.Wrapf(err, "cli: invalid --rechain %q", v)
+				}
+				policy.Rechain = append(policy.Rechain, kind)
+			}
+			states := make([]*state.State, 0, len(args))
+			for _, path := range args {
+				s, err := importMergeSide(path)
+				if err != nil {
+					return err
+				}
+				states = append(states, s)
+			}
+			clock, err := resolveClock()
+			if err != nil {
+				return err
+			}
+			states[1].Clock = clock
+			merged, err := state.Merge(states[0], states[1], states[2], policy)
+			if err != nil {
+				return eris.Wrap(err, "cli: could not merge state files")
+			}
+			if err := merged.Export(args[1]); err != nil {
+				return eris.Wrap(err, "cli: could not export merged state file")
+			}
+			if head, err := merged.Head(); err == nil {
+				logger.OK(fmt.Sprintf("state files merged, head is %s", head))
+			}
+			notify(logger, merged.Events())
+			return nil
+		},
+	}
+	cmd.Flags().StringSliceVarP(&rechain, "rechain", "", []string{state.ReleaseKindDev.String()}, "Release kinds that may be re-linked and re-hashed")
+	cmd.Flags().BoolVarP(&install, "install", "", false, "Configure the merge driver for the state file in the current repository")
+	return cmd
+}
+
+// importMergeSide imports a state file given by git. Git passes an empty file
+// as the base if the state file did not exist in the merge base.
+func importMergeSide(path string) (*state.State, error) {
+	s := state.NewState()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, eris.Wrap(err, "cli: could not read state file")
+	}
+	if len(strings.TrimSpace(string(b))) == 0 {
+		return s, nil
+	}
+	if err := s.Load(b); err != nil {
+		return nil, eris.Wrapf(err, "cli: could not import state file %s", path)
+	}
+	return s, nil
+}
+
+// installMergeDriver configures the merge driver in the git config of the current repository
+// and declares it for the state file in .gitattributes.
+func installMergeDriver(logger *Logger, rechain []string) error {
+	repo, err := git.Open(".")
+	if err != nil {
+		return err
+	}
+	driver := "microstate merge-driver"
+	if len(rechain) != 1 || rechain[0] != state.ReleaseKindDev.String() {
+		driver += " --rechain " + strings.Join(rechain, ",")
+	}
+	driver += " %O %A %B"
+	for key, value := range map[string]string{
+		"merge." + mergeDriverName + ".name":   "microstate state file merge",
+		"merge." + mergeDriverName + ".driver": driver,
+	} {
+		if _, err := repo.Run("config", key, value); err != nil {
+			return eris.Wrap(err, "cli: could not configure merge driver")
+		}
+	}
+	line := filepath.Base(FileName) + " merge=" + mergeDriverName
+	b, err := os.ReadFile(".gitattributes")
+	if err != nil && !os.IsNotExist(err) {
+		return eris.Wrap(err, "cli: could not read .gitattributes")
+	}
+	for _, v := range strings.Split(string(b), "\n") {
+		if strings.TrimSpace(v) == line {
+			logger.OK("merge driver installed")
+			return nil
+		}
+	}
+	content := string(b)
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	if err := os.WriteFile(".gitattributes", []byte(content+line+"\n"), 0644); err != nil {
+		return eris.Wrap(err, "cli: could not write .gitattributes")
+	}
+	logger.OK("merge driver installed")
+	return nil
+}
@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+	"github.com/spf13/cobra"
+)
+
+func NewMigrateCmd() *cobra.Command {
+	var (
+		store  = state.NewState()
+		logger = NewLogger()
+	)
+	var (
+		serviceCase string
+		versionCase string
+		dryRun      bool
+	)
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Normalize the service names and versions of every release",
+		Long: "Normalize the service names and versions of every release with the rules of the service registry.\n" +
+			"Changed releases are re-hashed and the rewrite is recorded as a checkpoint.\n" +
+			"Versions lower cased by older releases of microstate can not be restored.",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := store.Import(FileName); err != nil {
+				return eris.Wrap(err, "cli: could not import state file")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			registry, err := loadRegistry()
+			if err != nil {
+				return err
+			}
+			clock, err := resolveClock()
+			if err != nil {
+				return err
+			}
+			rules := registry.Rules()
+			if serviceCase != "" {
+				rules.Service = state.CaseRule(serviceCase)
+			}
+			if versionCase != "" {
+				rules.Version = state.CaseRule(versionCase)
+			}
+			if err := rules.Validate(); err != nil {
+				return eris.Wrap(err, "cli: invalid normalization rules")
+			}
+			var checkpoint *state.Checkpoint
+			migrate := func(store *state.State) error {
+				store.Clock = clock
+				c, err := store.Normalize(rules)
+				if err != nil {
+					return eris.Wrap(err, "cli: could not migrate")
+				}
+				checkpoint = c
+				return nil
+			}
+			if dryRun {
+				if err := migrate(store); err != nil {
+					return err
+				}
+				if checkpoint == nil {
+					logger.OK("ledger is already normalized")
+					return nil
+				}
+				logger.OK(fmt.Sprintf("%d releases would be rewritten", len(checkpoint.Rechained)))
+				return nil
+			}
+			if err := mutate(store, "migrate", migrate); err != nil {
+				return err
+			}
+			if checkpoint == nil {
+				logger.OK("ledger is already normalized")
+				return nil
+			}
+			if err := save(store); err != nil {
+				return err
+			}
+			logger.OK(fmt.Sprintf("rewrote %d releases, checkpoint %s", len(checkpoint.Rechained), checkpoint.Hash.Short()))
+			notify(logger, store.Events())
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&serviceCase, "service-case", "", "", "Override the case rule of service names. One of lower or preserve")
+	cmd.Flags().StringVarP(&versionCase, "version-case", "", "", "Override the case rule of versions. One of lower or preserve")
+	cmd.Flags().BoolVarP(&dryRun, "dry-run", "", false, "Only print the number of releases that would be rewritten")
+	return cmd
+}
@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"github.com/hsblhsn/microstate/git"
+	"github.com/hsblhsn/microstate/hook"
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+)
+
+// mutate applies the operation to the state and runs the pre hooks of the change,
+// a failing pre hook aborts the operation before the state is saved.
+// If the git store is configured, the state is loaded from the branch instead
+// and the change is committed. The operation is replayed if the branch moved,
+// so it must only depend on the given state. The pre hooks only run again
+// if the replayed change describes different releases, e.g. the next dev tag changed.
+func mutate(store *state.State, operation string, op func(s *state.State) error) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if cfg.Git == nil || cfg.Git.Store == nil {
+		if err := op(store); err != nil {
+			return err
+		}
+		return cfg.runHooks(hook.Pre, store.Events())
+	}
+	gs, err := cfg.Git.Store.Open()
+	if err != nil {
+		return err
+	}
+	pre := &hook.Once{Runner: &hook.Runner{Hooks: cfg.Hooks}}
+	result, err := gs.Apply(func(s *state.State) (string, error) {
+		// a rollback is described by the release it removes
+		r, err := s.Head()
+		if err != nil && operation == "rollback" {
+			return "", err
+		}
+		if err := op(s); err != nil {
+			return "", err
+		}
+		if err := pre.Run(hook.Pre, s.Events()); err != nil {
+			return "", err
+		}
+		if operation != "rollback" {
+			// the head after the change, nil if the change left the ledger empty
+			r, _ = s.Head()
+		}
+		return git.CommitMessage(operation, r), nil
+	})
+	if err != nil {
+		return eris.Wrap(err, "cli: could not commit state")
+	}
+	*store = *result
+	return nil
+}
+
+// save exports the state file, unless the git store is configured.
+// The git store already committed the change and updated the working tree copy if the branch is checked out.
+func save(store *state.State) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if cfg.Git != nil && cfg.Git.Store != nil {
+		return nil
+	}
+	if err := store.Export(FileName); err != nil {
+		return eris.Wrap(err, "cli: could not export state file")
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/franela/goblin"
+	"github.com/hsblhsn/microstate/state"
+)
+
+func TestMutate(t *testing.T) {
+	g := goblin.Goblin(t)
+	for k, v := range map[string]string{
+		"GIT_AUTHOR_NAME":     "test",
+		"GIT_AUTHOR_EMAIL":    "test@example.com",
+		"GIT_COMMITTER_NAME":  "test",
+		"GIT_COMMITTER_EMAIL": "test@example.com",
+		"GIT_CONFIG_GLOBAL":   os.DevNull,
+	} {
+		t.Setenv(k, v)
+	}
+	dir, logFile := t.TempDir(), filepath.Join(t.TempDir(), "hooks.log")
+	wd, err := os.Getwd()
+	g.Assert(err).IsNil()
+	g.Assert(os.Chdir(dir)).IsNil()
+	defer os.Chdir(wd)
+
+	git := func(args ...string) string {
+		out, err := exec.Command("git", args...).CombinedOutput()
+		g.Assert(err == nil).IsTrue(string(out))
+		return strings.TrimSpace(string(out))
+	}
+
+	g.Describe("mutate", func() {
+		g.It("should commit a change that leaves the ledger empty", func() {
+			git("init", "--quiet")
+			git("commit", "--quiet", "--allow-empty", "--message", "init")
+			config := `{"git": {"store": {}}, "hooks": [{"when": "pre", "kind": "dev", "run": "echo $MICROSTATE_TAG >> ` + logFile + `"}]}`
+			g.Assert(os.WriteFile(ConfigFileName, []byte(config), 0644)).IsNil()
+
+			store := state.NewState()
+			g.Assert(mutate(store, "publish", func(s *state.State) error {
+				r, err := state.NewRelease(state.ReleaseKindDev, "v0.0.1-dev", state.VersionMap{"user-service": {Version: "3db20cf"}})
+				if err != nil {
+					return err
+				}
+				return s.CreateRelease(r)
+			})).IsNil()
+			g.Assert(mutate(store, "prune", func(s *state.State) error {
+				_, err := s.Prune(func(int, *state.Release) bool { return true }, "test")
+				return err
+			})).IsNil()
+			g.Assert(len(store.Releases)).Equal(0)
+			g.Assert(len(store.Checkpoints)).Equal(1)
+
+			g.Assert(git("log", "-1", "--format=%B")).Equal("microstate: prune, ledger is empty\n\nMicrostate-Operation: prune")
+			committed := state.NewState()
+			g.Assert(committed.Load([]byte(git("show", "HEAD:"+filepath.Base(FileName))))).IsNil()
+			g.Assert(len(committed.Releases)).Equal(0)
+			g.Assert(len(committed.Checkpoints)).Equal(1)
+
+			log, err := os.ReadFile(logFile)
+			g.Assert(err).IsNil()
+			g.Assert(string(log)).Equal("v0.0.1-dev\n")
+		})
+	})
+}
@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/hsblhsn/microstate/hook"
+	"github.com/hsblhsn/microstate/state"
+)
+
+// notify runs the post hooks of the events of a saved state,
+// appends them to the configured event stream and publishes them to the configured webhooks.
+// Failures are reported as warnings, the state is already saved.
+func notify(logger *Logger, events []state.Event) {
+	if len(events) == 0 {
+		return
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Warn(err)
+		return
+	}
+	if err := cfg.runHooks(hook.Post, events); err != nil {
+		logger.Warn(err)
+	}
+	if cfg.Events != nil && cfg.Events.File != "" {
+		if err := cfg.Events.Writer().Write(events); err != nil {
+			logger.Warn(err)
+		}
+	}
+	if cfg.Webhooks != nil && len(cfg.Webhooks.Endpoints) != 0 {
+		deliveries, err := cfg.Webhooks.Dispatcher().Dispatch(events)
+		if err != nil {
+			logger.Warn(fmt.Sprintf("could not deliver webhooks: %v", err))
+		}
+		for _, d := range deliveries {
+			if d.OK() {
+				logger.OK(fmt.Sprintf("delivered %s to %s", d.Event, d.URL))
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strings"
+
+	"github.com/hsblhsn/microstate/state"
+)
+
+// Version of microstate. It is set at build time.
+// e.g. go build -ldflags "-X github.com/hsblhsn/microstate/cli.Version=v0.2.0"
+var Version = "dev"
+
+// resolveOrigin detects the origin of a release.
+// The actor is resolved in the following order:
+// the given author, CI environment variables, git config and the os user.
+func resolveOrigin(author string) state.Origin {
+	origin := state.Origin{
+		Actor:    author,
+		Pipeline: detectPipeline(),
+		Version:  Version,
+	}
+	if origin.Actor == "" {
+		origin.Actor = detectActor()
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		origin.Hostname = hostname
+	}
+	return origin
+}
+
+func detectActor() string {
+	for _, env := range []string{"MICROSTATE_AUTHOR", "GITHUB_ACTOR", "GITLAB_USER_LOGIN", "BUILD_USER_ID"} {
+		if v := os.Getenv(env); v != "" {
+			return v
+		}
+	}
+	name := gitConfig("user.name")
+	email := gitConfig("user.email")
+	switch {
+	case name != "" && email != "":
+		return fmt.Sprintf("%s <%s>", name, email)
+	case name != "":
+		return name
+	case email != "":
+		return email
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return ""
+}
+
+func detectPipeline() string {
+	if id := os.Getenv("GITHUB_RUN_ID"); id != "" {
+		return fmt.Sprintf("github:%s/%s", os.Getenv("GITHUB_WORKFLOW"), id)
+	}
+	if id := os.Getenv("CI_JOB_ID"); id != "" {
+		return fmt.Sprintf("gitlab:%s", id)
+	}
+	if id := os.Getenv("BUILD_TAG"); id != "" {
+		return fmt.Sprintf("jenkins:%s", id)
+	}
+	return ""
+}
+
+func gitConfig(key string) string {
+	out, err := exec.Command("git", "config", "--get", key).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"testing"
+
+	"github.com/franela/goblin"
+)
+
+func TestResolveOrigin(t *testing.T) {
+	g := goblin.Goblin(t)
+	envs := []string{
+		"MICROSTATE_AUTHOR", "GITHUB_ACTOR", "GITLAB_USER_LOGIN", "BUILD_USER_ID",
+		"GITHUB_RUN_ID", "GITHUB_WORKFLOW", "CI_JOB_ID", "BUILD_TAG",
+	}
+	for _, env := range envs {
+		t.Setenv(env, "")
+	}
+	// git config is read from an empty global file outside of any repository
+	dir := t.TempDir()
+	gitconfig := filepath.Join(dir, "gitconfig")
+	t.Setenv("GIT_CONFIG_GLOBAL", gitconfig)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	wd, err := os.Getwd()
+	g.Assert(err).IsNil()
+	g.Assert(os.Chdir(dir)).IsNil()
+	defer os.Chdir(wd)
+
+	g.Describe("resolveOrigin", func() {
+		g.BeforeEach(func() {
+			for _, env := range envs {
+				g.Assert(os.Setenv(env, "")).IsNil()
+			}
+			g.Assert(os.WriteFile(gitconfig, nil, 0644)).IsNil()
+		})
+		g.It("should prefer the given author", func() {
+			g.Assert(os.Setenv("MICROSTATE_AUTHOR", "bot")).IsNil()
+			origin := resolveOrigin("jane")
+			g.Assert(origin.Actor).Equal("jane")
+			g.Assert(origin.Version).Equal(Version)
+		})
+		g.It("should read the CI environment", func() {
+			g.Assert(os.Setenv("GITHUB_ACTOR", "octocat")).IsNil()
+			g.Assert(os.Setenv("GITHUB_RUN_ID", "42")).IsNil()
+			g.Assert(os.Setenv("GITHUB_WORKFLOW", "release")).IsNil()
+			origin := resolveOrigin("")
+			g.Assert(origin.Actor).Equal("octocat")
+			g.Assert(origin.Pipeline).Equal("github:release/42")
+		})
+		g.It("should read the git config", func() {
+			g.Assert(os.WriteFile(gitconfig, []byte("[user]\n\tname = Jane Doe\n\temail = jane@example.com\n"), 0644)).IsNil()
+			origin := resolveOrigin("")
+			g.Assert(origin.Actor).Equal("Jane Doe <jane@example.com>")
+			g.Assert(origin.Pipeline).Equal("")
+		})
+		g.It("should fall back to the os user", func() {
+			u, err := user.Current()
+			g.Assert(err).IsNil()
+			origin := resolveOrigin("")
+			g.Assert(origin.Actor).Equal(u.Username)
+			hostname, err := os.Hostname()
+			g.Assert(err).IsNil()
+			g.Assert(origin.Hostname).Equal(hostname)
+		})
+	})
+}
@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+	"github.com/spf13/cobra"
+)
+
+func NewPruneCmd() *cobra.Command {
+	var (
+		store  = state.NewState()
+		logger = NewLogger()
+	)
+	var (
+		kind       string
+		olderThan  string
+		keepLast   int
+		keepLatest bool
+		dryRun     bool
+	)
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove releases from the state and re-link the remaining chain",
+		Long: "Remove releases matching all the given criteria from the state.\n" +
+			"Releases following a removed one are re-linked and re-hashed, the rewrite is recorded as a checkpoint.",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := store.Import(FileName); err != nil {
+				return eris.Wrap(err, "cli: could not import state file")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if kind == "" && olderThan == "" && keepLast <= 0 {
+				return eris.New("cli: at least one of --kind, --older-than or --keep-last is required")
+			}
+			clock, err := resolveClock()
+			if err != nil {
+				return err
+			}
+			var filter state.ReleaseKind
+			if kind != "" {
+				k, err := state.NewReleaseKindFromString(kind)
+				if err != nil {
+					return eris.Wrap(err, "cli: invalid kind")
+				}
+				filter = k
+			}
+			var cutoff time.Time
+			if olderThan != "" {
+				age, err := parseAge(olderThan)
+				if err != nil {
+					return err
+				}
+				cutoff = clock.Now().Add(-age)
+			}
+			var (
+				matched int
+				latest  map[state.ReleaseKind]bool
+				reasons = make([]string, 0, 3)
+			)
+			if filter != 0 {
+				reasons = append(reasons, "kind="+filter.String())
+			}
+			if olderThan != "" {
+				reasons = append(reasons, "older-than="+olderThan)
+			}
+			if keepLast > 0 {
+				reasons = append(reasons, fmt.Sprintf("keep-last=%d", keepLast))
+			}
+			match := func(_ int, r *state.Release) bool {
+				isLatest := !latest[r.Kind]
+				latest[r.Kind] = true
+				if filter != 0 && !r.Kind.Is(filter) {
+					return false
+				}
+				matched++
+				if matched <= keepLast {
+					return false
+				}
+				if !cutoff.IsZero() && !r.CreatedAt.Before(cutoff) {
+					return false
+				}
+				if keepLatest && isLatest {
+					return false
+				}
+				if dryRun {
+					fmt.Printf("would prune %s\n", r.String())
+					return false
+				}
+				return true
+			}
+			var checkpoint *state.Checkpoint
+			prune := func(store *state.State) error {
+				matched, latest = 0, make(map[state.ReleaseKind]bool)
+				store.Clock = clock
+				c, err := store.Prune(match, "prune "+strings.Join(reasons, " "))
+				if err != nil {
+					return eris.Wrap(err, "cli: could not prune")
+				}
+				checkpoint = c
+				return nil
+			}
+			if dryRun {
+				return prune(store)
+			}
+			if err := mutate(store, "prune", prune); err != nil {
+				return err
+			}
+			if checkpoint == nil {
+				logger.OK("nothing to prune")
+				return nil
+			}
+			if err := save(store); err != nil {
+				return err
+			}
+			logger.OK(fmt.Sprintf(
+				"pruned %d releases, re-linked %d releases, checkpoint %s",
+				len(checkpoint.Pruned), len(checkpoint.Rechained), checkpoint.Hash.Short(),
+			))
+			notify(logger, store.Events())
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&kind, "kind", "k", "", "Only prune releases of the given kind")
+	cmd.Flags().StringVarP(&olderThan, "older-than", "", "", "Only prune releases older than the given age (e.g. 720h, 90d)")
+	cmd.Flags().IntVarP(&keepLast, "keep-last", "", 0, "Keep the given number of newest releases matching --kind")
+	cmd.Flags().BoolVarP(&keepLatest, "keep-latest", "", true, "Never prune the latest release of a kind")
+	cmd.Flags().BoolVarP(&dryRun, "dry-run", "", false, "Print the releases that would be pruned")
+	return cmd
+}
+
+// parseAge parses a duration that additionally accepts a day suffix (e.g. 90d).
+func parseAge(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, eris.Wrapf(err, "cli: invalid age %q", s)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, eris.Wrapf(err, "cli: invalid age %q", s)
+	}
+	return d, nil
+}
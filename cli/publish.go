@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"os"
+	"strings"
+
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+	"github.com/spf13/cobra"
+)
+
+// publishOptions holds the flags shared by all the publish commands.
+type publishOptions struct {
+	annotations []string
+	author      string
+	notes       string
+	notesFile   string
+}
+
+func (o *publishOptions) register(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&o.author, "author", "", "", "Author of the release. Detected from CI environment or git config by default")
+	cmd.Flags().StringVarP(&o.notes, "notes", "", "", "Release notes")
+	cmd.Flags().StringVarP(&o.notesFile, "notes-file", "", "", "Read the release notes from a file")
+	cmd.Flags().StringArrayVarP(&o.annotations, "annotate", "a", make([]string, 0),
+		"Annotate the release. It accepts array of values. (e.g. --annotate build=https://ci/42 --annotate ticket=OPS-1)",
+	)
+}
+
+// prepare configures the state for creating releases.
+func (o *publishOptions) prepare(store *state.State) error {
+	clock, err := resolveClock()
+	if err != nil {
+		return err
+	}
+	store.Clock = clock
+	store.Origin = resolveOrigin(o.author)
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+	store.Registry = registry
+	return nil
+}
+
+// loadRegistry imports the service registry.
+// It returns an empty registry if the registry file does not exist.
+func loadRegistry() (*state.Registry, error) {
+	registry := state.NewRegistry()
+	if err := registry.Import(RegistryFileName); err != nil {
+		if os.IsNotExist(eris.Cause(err)) {
+			return registry, nil
+		}
+		return nil, eris.Wrap(err, "cli: could not import service registry")
+	}
+	return registry, nil
+}
+
+// releaseOptions converts the flags to release options.
+func (o *publishOptions) releaseOptions() ([]state.ReleaseOption, error) {
+	annotations := make(state.Annotations)
+	for _, v := range o.annotations {
+		key, value, err := state.ParseAnnotation(v)
+		if err != nil {
+			return nil, eris.Wrap(err, "cli: could not parse annotation")
+		}
+		annotations[key] = value
+	}
+	notes := o.notes
+	if o.notesFile != "" {
+		if notes != "" {
+			return nil, eris.New("cli: --notes and --notes-file are mutually exclusive")
+		}
+		b, err := os.ReadFile(o.notesFile)
+		if err != nil {
+			return nil, eris.Wrap(err, "cli: could not read notes file")
+		}
+		notes = string(b)
+	}
+	return []state.ReleaseOption{
+		state.WithAnnotations(annotations),
+		state.WithNotes(strings.TrimSpace(notes)),
+	}, nil
+}
+
+func NewAlphaCmd() *cobra.Command {
+	return newPromoteCmd(state.ReleaseKindAlpha)
+}
+
+func NewBetaCmd() *cobra.Command {
+	return newPromoteCmd(state.ReleaseKindBeta)
+}
+
+func NewRCCmd() *cobra.Command {
+	return newPromoteCmd(state.ReleaseKindRC)
+}
+
+func NewGACmd() *cobra.Command {
+	return newPromoteCmd(state.ReleaseKindGA)
+}
+
+func NewEOLCmd() *cobra.Command {
+	return newPromoteCmd(state.ReleaseKindEOL)
+}
+
+func NewUnsupportedCmd() *cobra.Command {
+	return newPromoteCmd(state.ReleaseKindUnsupported)
+}
+
+// newPromoteCmd returns a command that promotes the latest release of the previous kind to the given kind.
+func newPromoteCmd(kind state.ReleaseKind) *cobra.Command {
+	var (
+		store  = state.NewState()
+		logger = NewLogger()
+		opts   = new(publishOptions)
+	)
+	cmd := &cobra.Command{
+		Use: kind.String(),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := store.Import(FileName); err != nil {
+				return eris.Wrap(err, "cli: could not import state file")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			releaseOpts, err := opts.releaseOptions()
+			if err != nil {
+				return err
+			}
+			return mutate(store, "publish", func(store *state.State) error {
+				if err := opts.prepare(store); err != nil {
+					return err
+				}
+				if err := store.PromoteTo(kind, releaseOpts...); err != nil {
+					return eris.Wrap(err, "cli: could not promote")
+				}
+				return nil
+			})
+		},
+		PostRunE: func(cmd *cobra.Command, args []string) error {
+			if err := save(store); err != nil {
+				return err
+			}
+			logger.Promotion(store, kind)
+			notify(logger, store.Events())
+			autoTag(logger, store.Latest(kind))
+			return nil
+		},
+	}
+	opts.register(cmd)
+	return cmd
+}
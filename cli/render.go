@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"io"
+	"os"
+
+	"github.com/hsblhsn/microstate/render"
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+	"github.com/spf13/cobra"
+)
+
+func NewRenderCmd() *cobra.Command {
+	var (
+		store = state.NewState()
+	)
+	var (
+		templateFile string
+		release      string
+		output       string
+	)
+	cmd := &cobra.Command{
+		Use:   "render",
+		Short: "Render a release with a Go text/template",
+		Long: "Render a release with a Go text/template.\n\n" +
+			"The template receives .Release, .Versions, .Previous (the previous release of the same kind, or nil)\n" +
+			"and .Changes (the service changes since the previous release).\n" +
+			"Helper functions: sortedServices, shortHash, semver, major, minor, patch, prerelease,\n" +
+			"env, snake, upper and lower.",
+		Example: "  microstate render --template deploy.sh.tmpl --release rc -o deploy.sh",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := store.Import(FileName); err != nil {
+				return eris.Wrap(err, "cli: could not import state file")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var (
+				r   *state.Release
+				err error
+			)
+			if release == "" {
+				r, err = store.Head()
+			} else {
+				r, err = store.Resolve(release)
+			}
+			if err != nil {
+				return eris.Wrap(err, "cli: could not find release")
+			}
+			text, err := os.ReadFile(templateFile)
+			if err != nil {
+				return eris.Wrap(err, "cli: could not read template")
+			}
+			var w io.Writer = os.Stdout
+			if output != "" {
+				f, err := os.Create(output)
+				if err != nil {
+					return eris.Wrap(err, "cli: could not create output file")
+				}
+				defer f.Close()
+				w = f
+			}
+			data := render.NewData(r, store.Previous(r))
+			return render.Template(w, templateFile, string(text), data)
+		},
+	}
+	cmd.Flags().StringVarP(&templateFile, "template", "t", "", "Template file")
+	cmd.Flags().StringVarP(&release, "release", "r", "", "Release to render (kind, tag or hash). Defaults to the latest release")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Write to the given file instead of stdout")
+	_ = cmd.MarkFlagRequired("template")
+	return cmd
+}
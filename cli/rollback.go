@@ -22,18 +22,21 @@ func NewRollbackCmd() *cobra.Command {
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			store.Rollback()
-			return nil
+			return mutate(store, "rollback", func(store *state.State) error {
+				store.Rollback()
+				return nil
+			})
 		},
 		PostRunE: func(cmd *cobra.Command, args []string) error {
-			if err := store.Export(FileName); err != nil {
-				return eris.Wrap(err, "cli: could not export state file")
+			if err := save(store); err != nil {
+				return err
 			}
 			top, err := store.Head()
 			if err != nil {
 				return eris.Wrap(err, "cli: could not get head release")
 			}
 			logger.OK(fmt.Sprintf("rolled back to %s", top.String()))
+			notify(logger, store.Events())
 			return nil
 		},
 	}
@@ -8,11 +8,19 @@ import (
 	"github.com/spf13/cobra"
 )
 
-const FileName = state.DefaultFileName
+const (
+	FileName            = state.DefaultFileName
+	ArchiveDir          = state.DefaultArchiveDir
+	RegistryFileName    = state.DefaultRegistryFileName
+	ConfigFileName      = "./.microstate.json"
+	DeliveryLogFileName = "./.webhooks.log"
+	WatchCursorFileName = "./.watch.cursor.json"
+)
 
 func NewRootCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use: "microstate",
+		Use:     "microstate",
+		Version: Version,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			fmt.Println("Use --help to see available commands.")
 			os.Exit(1)
@@ -30,6 +38,24 @@ func NewRootCmd() *cobra.Command {
 	init := NewInitCmd()
 	rollback := NewRollbackCmd()
 	status := NewStatusCmd()
+	log := NewLogCmd()
+	show := NewShowCmd()
+	changelog := NewChangelogCmd()
+	prune := NewPruneCmd()
+	archive := NewArchiveCmd()
+	checkpoint := NewCheckpointCmd()
+	verify := NewVerifyCmd()
+	services := NewServicesCmd()
+	migrate := NewMigrateCmd()
+	compose := NewComposeCmd()
+	export := NewExportCmd()
+	render := NewRenderCmd()
+	tag := NewTagCmd()
+	mergeDriver := NewMergeDriverCmd()
+	serve := NewServeCmd()
+	token := NewTokenCmd()
+	events := NewEventsCmd()
+	watch := NewWatchCmd()
 	dev := NewDevCmd()
 	alpha := NewAlphaCmd()
 	beta := NewBetaCmd()
@@ -38,6 +64,6 @@ func NewRootCmd() *cobra.Command {
 	eol := NewEOLCmd()
 	unsupported := NewUnsupportedCmd()
 	publish.AddCommand(dev, alpha, beta, rc, ga, eol, unsupported)
-	cmd.AddCommand(init, status, publish, rollback)
+	cmd.AddCommand(init, status, log, show, changelog, publish, rollback, prune, archive, checkpoint, verify, services, migrate, compose, export, render, tag, mergeDriver, serve, token, events, watch)
 	return cmd
 }
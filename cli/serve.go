@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/hsblhsn/microstate/hook"
+	"github.com/hsblhsn/microstate/server"
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+	"github.com/spf13/cobra"
+)
+
+func NewServeCmd() *cobra.Command {
+	var (
+		logger = NewLogger()
+	)
+	var (
+		addr     string
+		memory   bool
+		authFile string
+	)
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve the ledger over a REST API",
+		Long: "Serve the ledger over a REST API.\n\n" +
+			"By default the state file is read on every request and written on every change.\n" +
+			"With --memory the state file is loaded once, if it exists, and changes are kept in memory.\n\n" +
+			"With --auth every request needs a bearer token. The auth file declares static tokens,\n" +
+			"the environment variable of the secret of signed tokens (see 'microstate token')\n" +
+			"and the operations allowed per role: read, publish-dev, promote-to-<kind> and rollback-<kind> of the head release.\n" +
+			"  {\"tokens\": [{\"name\": \"deploy-bot\", \"token\": \"...\", \"roles\": [\"reader\"]}],\n" +
+			"   \"hmac_secret_env\": \"MICROSTATE_HMAC_SECRET\",\n" +
+			"   \"roles\": {\"reader\": [\"read\"], \"release-manager\": [\"read\", \"promote-to-*\"]}}",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var backend server.Backend = server.NewFileBackend(FileName)
+			if memory {
+				initial := state.NewState()
+				if err := initial.Import(FileName); err != nil && !os.IsNotExist(eris.Cause(err)) {
+					return eris.Wrap(err, "cli: could not import state file")
+				}
+				b, err := server.NewMemoryBackend(initial)
+				if err != nil {
+					return err
+				}
+				backend = b
+			}
+			srv := server.New(backend)
+			if authFile != "" {
+				auth, err := server.LoadAuth(authFile)
+				if err != nil {
+					return eris.Wrap(err, "cli: could not load auth file")
+				}
+				srv.Auth = auth
+			}
+			srv.Prepare = func(s *state.State, r *http.Request) error {
+				clock, err := resolveClock()
+				if err != nil {
+					return err
+				}
+				s.Clock = clock
+				s.Origin = resolveOrigin("")
+				registry, err := loadRegistry()
+				if err != nil {
+					return err
+				}
+				s.Registry = registry
+				return nil
+			}
+			srv.BeforeSave = func(s *state.State) error {
+				cfg, err := loadConfig()
+				if err != nil {
+					return err
+				}
+				return cfg.runHooks(hook.Pre, s.Events())
+			}
+			srv.OnEvents = func(events []state.Event) {
+				go notify(logger, events)
+			}
+			logger.OK(fmt.Sprintf("serving %s on %s", FileName, addr))
+			return http.ListenAndServe(addr, srv)
+		},
+	}
+	cmd.Flags().StringVarP(&addr, "addr", "", ":8080", "Address to listen on")
+	cmd.Flags().BoolVarP(&memory, "memory", "", false, "Keep the state in memory instead of writing the state file")
+	cmd.Flags().StringVarP(&authFile, "auth", "", "", "Require tokens declared in the given auth file and authorize their roles")
+	return cmd
+}
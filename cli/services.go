@@ -0,0 +1,188 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+	"github.com/spf13/cobra"
+)
+
+func NewServicesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "services",
+		Short: fmt.Sprintf("Manage the service registry in %s", RegistryFileName),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fmt.Println("Use --help to see available commands.")
+			os.Exit(1)
+			return nil
+		},
+	}
+	cmd.AddCommand(newServicesAddCmd(), newServicesRemoveCmd(), newServicesListCmd(), newConstraintsCmd())
+	return cmd
+}
+
+func newServicesAddCmd() *cobra.Command {
+	var (
+		logger = NewLogger()
+		svc    = new(state.Service)
+	)
+	var (
+		format  string
+		length  int
+		pattern string
+	)
+	cmd := &cobra.Command{
+		Use:   "add <name>",
+		Short: "Register a service or update a registered one",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			registry, err := loadRegistry()
+			if err != nil {
+				return err
+			}
+			if format != "" {
+				svc.Format = &state.VersionFormat{
+					Type:    state.FormatType(format),
+					Length:  length,
+					Pattern: pattern,
+				}
+			}
+			if err := registry.Add(args[0], svc); err != nil {
+				return eris.Wrap(err, "cli: could not add service")
+			}
+			if err := registry.Export(RegistryFileName); err != nil {
+				return eris.Wrap(err, "cli: could not export service registry")
+			}
+			logger.OK(fmt.Sprintf("service %s registered", strings.ToLower(args[0])))
+			return nil
+		},
+	}
+	cmd.Flags().StringArrayVarP(&svc.Owners, "owner", "o", make([]string, 0), "Owner of the service. It accepts array of values.")
+	cmd.Flags().StringVarP(&svc.Repository, "repository", "r", "", "Source code repository url")
+	cmd.Flags().StringVarP(&svc.Image, "image", "i", "", "Container image repository")
+	cmd.Flags().StringVarP(&format, "format", "f", "", "Version format. One of sha, semver, digest or regex")
+	cmd.Flags().IntVarP(&length, "length", "", 0, "Exact sha length of the sha format")
+	cmd.Flags().StringVarP(&pattern, "pattern", "", "", "Regular expression of the regex format")
+	return cmd
+}
+
+func newServicesRemoveCmd() *cobra.Command {
+	var (
+		logger = NewLogger()
+	)
+	return &cobra.Command{
+		Use:   "remove <name>",
+		Short: "Unregister a service",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			registry, err := loadRegistry()
+			if err != nil {
+				return err
+			}
+			if err := registry.Remove(args[0]); err != nil {
+				return eris.Wrap(err, "cli: could not remove service")
+			}
+			if err := registry.Export(RegistryFileName); err != nil {
+				return eris.Wrap(err, "cli: could not export service registry")
+			}
+			logger.OK(fmt.Sprintf("service %s removed", strings.ToLower(args[0])))
+			return nil
+		},
+	}
+}
+
+func newServicesListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the registered services",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			registry, err := loadRegistry()
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tFORMAT\tOWNERS\tREPOSITORY\tIMAGE")
+			for _, name := range registry.Names() {
+				svc, _ := registry.Get(name)
+				format := "-"
+				if svc.Format != nil {
+					format = string(svc.Format.Type)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", name, format, orDash(strings.Join(svc.Owners, ",")), orDash(svc.Repository), orDash(svc.Image))
+			}
+			return w.Flush()
+		},
+	}
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func newConstraintsCmd() *cobra.Command {
+	var (
+		logger = NewLogger()
+	)
+	cmd := &cobra.Command{
+		Use:   "constraints",
+		Short: "List the declared constraints between service versions",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			registry, err := loadRegistry()
+			if err != nil {
+				return err
+			}
+			for _, c := range registry.Constraints {
+				fmt.Println(c)
+			}
+			return nil
+		},
+	}
+	add := &cobra.Command{
+		Use:     "add <constraint>",
+		Short:   "Declare a constraint",
+		Example: `  microstate services constraints add "gateway-service>=2.3 requires user-service>=1.8"`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			registry, err := loadRegistry()
+			if err != nil {
+				return err
+			}
+			if err := registry.AddConstraint(args[0]); err != nil {
+				return eris.Wrap(err, "cli: could not add constraint")
+			}
+			if err := registry.Export(RegistryFileName); err != nil {
+				return eris.Wrap(err, "cli: could not export service registry")
+			}
+			logger.OK(fmt.Sprintf("constraint %q declared", args[0]))
+			return nil
+		},
+	}
+	remove := &cobra.Command{
+		Use:   "remove <constraint>",
+		Short: "Remove a declared constraint",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			registry, err := loadRegistry()
+			if err != nil {
+				return err
+			}
+			if err := registry.RemoveConstraint(args[0]); err != nil {
+				return eris.Wrap(err, "cli: could not remove constraint")
+			}
+			if err := registry.Export(RegistryFileName); err != nil {
+				return eris.Wrap(err, "cli: could not export service registry")
+			}
+			logger.OK(fmt.Sprintf("constraint %q removed", args[0]))
+			return nil
+		},
+	}
+	cmd.AddCommand(add, remove)
+	return cmd
+}
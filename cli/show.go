@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+	"github.com/spf13/cobra"
+)
+
+func NewShowCmd() *cobra.Command {
+	var (
+		store = state.NewState()
+	)
+	var (
+		asJSON bool
+	)
+	cmd := &cobra.Command{
+		Use:   "show <kind|tag|hash>",
+		Short: "Show the details of a release",
+		Args:  cobra.ExactArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := store.Import(FileName); err != nil {
+				return eris.Wrap(err, "cli: could not import state file")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			r, err := store.Resolve(args[0])
+			if err != nil {
+				return eris.Wrap(err, "cli: could not find release")
+			}
+			if asJSON {
+				b, err := json.MarshalIndent(r, "", "\t")
+				if err != nil {
+					return eris.Wrap(err, "cli: could not encode release")
+				}
+				fmt.Println(string(b))
+				return nil
+			}
+			printRelease(os.Stdout, r)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVarP(&asJSON, "json", "", false, "Print the release as json")
+	return cmd
+}
+
+// printRelease writes a human readable representation of the release.
+func printRelease(w io.Writer, r *state.Release) {
+	fmt.Fprintf(w, "release  %s\n", r.String())
+	fmt.Fprintf(w, "hash     %s\n", r.BlockHash)
+	if !r.PreviousBlockHash.IsEmpty() {
+		fmt.Fprintf(w, "previous %s\n", r.PreviousBlockHash)
+	}
+	fmt.Fprintf(w, "created  %s\n", r.CreatedAt.Format("2006-01-02 15:04:05 -0700"))
+	if r.Origin != nil {
+		fmt.Fprintf(w, "author   %s\n", r.Origin.Actor)
+		if r.Origin.Pipeline != "" {
+			fmt.Fprintf(w, "pipeline %s\n", r.Origin.Pipeline)
+		}
+		if r.Origin.Hostname != "" {
+			fmt.Fprintf(w, "host     %s\n", r.Origin.Hostname)
+		}
+		if r.Origin.Version != "" {
+			fmt.Fprintf(w, "tool     microstate %s\n", r.Origin.Version)
+		}
+	}
+	if len(r.Annotations) != 0 {
+		fmt.Fprintln(w, "\nannotations:")
+		for _, k := range r.Annotations.Keys() {
+			fmt.Fprintf(w, "  %s=%s\n", k, r.Annotations[k])
+		}
+	}
+	if r.Notes != "" {
+		fmt.Fprintf(w, "\n%s\n", r.Notes)
+	}
+	fmt.Fprintln(w, "\nservices:")
+	for _, k := range r.Versions.Services() {
+		fmt.Fprintf(w, "  %s\n", state.FormatService(k, r.Versions[k]))
+	}
+}
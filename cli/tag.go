@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/hsblhsn/microstate/git"
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+	"github.com/spf13/cobra"
+)
+
+func NewTagCmd() *cobra.Command {
+	var (
+		store  = state.NewState()
+		logger = NewLogger()
+	)
+	cmd := &cobra.Command{
+		Use:   "tag <kind|tag|hash>",
+		Short: "Tag the service repositories and the ledger repository with a release",
+		Long: "Tag the service repositories and the ledger repository with a release.\n\n" +
+			"The local clone paths of the services are read from the git section of " + ConfigFileName + ".\n" +
+			"Service repositories are tagged on the recorded sha of the service,\n" +
+			"the ledger repository is tagged on the commit of the git store that recorded the release,\n" +
+			"or on HEAD if the release is the head of the committed state file.",
+		Args: cobra.ExactArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := store.Import(FileName); err != nil {
+				return eris.Wrap(err, "cli: could not import state file")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if cfg.Git == nil {
+				return eris.Errorf("cli: git integration is not configured in %s", ConfigFileName)
+			}
+			registry, err := loadRegistry()
+			if err != nil {
+				return err
+			}
+			r, err := store.Resolve(args[0])
+			if err != nil {
+				return eris.Wrap(err, "cli: could not find release")
+			}
+			results, err := cfg.Git.Tagger(registry.Rules()).Tag(r)
+			logTags(logger, results)
+			if err != nil {
+				return eris.Wrap(err, "cli: could not tag release")
+			}
+			return nil
+		},
+	}
+	return cmd
+}
+
+// autoTag tags the release if the git integration is configured for its kind.
+// The ledger is only tagged if the git store committed the release,
+// otherwise the state file is not committed yet.
+// Failures are reported as warnings, the release is already exported.
+func autoTag(logger *Logger, r *state.Release) {
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Warn(err)
+		return
+	}
+	if !cfg.Git.Tags(r.Kind) {
+		return
+	}
+	registry, err := loadRegistry()
+	if err != nil {
+		logger.Warn(err)
+		return
+	}
+	tagger := cfg.Git.Tagger(registry.Rules())
+	skipLedger := cfg.Git.Store == nil && tagger.Ledger != ""
+	if skipLedger {
+		tagger.Ledger = ""
+	}
+	results, err := tagger.Tag(r)
+	logTags(logger, results)
+	if err != nil {
+		logger.Warn(fmt.Sprintf("could not tag release: %v", err))
+		return
+	}
+	if skipLedger {
+		logger.Warn(fmt.Sprintf("ledger is not tagged, commit %s and run 'microstate tag %s'", FileName, r.Tag))
+	}
+}
+
+func logTags(logger *Logger, results []git.TagResult) {
+	for _, v := range results {
+		if v.Created {
+			logger.OK(fmt.Sprintf("tagged %s", v))
+		}
+	}
+}
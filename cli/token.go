@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hsblhsn/microstate/server"
+	"github.com/rotisserie/eris"
+	"github.com/spf13/cobra"
+)
+
+func NewTokenCmd() *cobra.Command {
+	var (
+		name      string
+		roles     []string
+		ttl       time.Duration
+		secretEnv string
+	)
+	cmd := &cobra.Command{
+		Use:   "token",
+		Short: "Sign a token for the server",
+		Long: "Sign a token for the server with the secret of the given environment variable.\n" +
+			"The server accepts it if its auth file names the same variable in hmac_secret_env.",
+		Example: "  MICROSTATE_HMAC_SECRET=... microstate token --name deploy-bot --role release-manager --ttl 720h",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if name == "" {
+				return eris.New("cli: --name is required")
+			}
+			secret := os.Getenv(secretEnv)
+			if secret == "" {
+				return eris.Errorf("cli: %s is not set", secretEnv)
+			}
+			claims := server.Claims{
+				Subject: name,
+				Roles:   roles,
+			}
+			if ttl > 0 {
+				// the server checks the expiry against the wall clock, SOURCE_DATE_EPOCH does not apply
+				claims.ExpiresAt = time.Now().Add(ttl).Unix()
+			}
+			h := &server.HMACTokens{Secret: []byte(secret)}
+			token, err := h.Sign(claims)
+			if err != nil {
+				return eris.Wrap(err, "cli: could not sign token")
+			}
+			fmt.Println(token)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&name, "name", "", "", "Identity recorded on the releases created with the token")
+	cmd.Flags().StringSliceVarP(&roles, "role", "r", make([]string, 0), "Roles of the token")
+	cmd.Flags().DurationVarP(&ttl, "ttl", "", 0, "Validity of the token. Zero never expires")
+	cmd.Flags().StringVarP(&secretEnv, "secret-env", "", "MICROSTATE_HMAC_SECRET", "Environment variable holding the secret")
+	return cmd
+}
@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/hsblhsn/microstate/client"
+	"github.com/hsblhsn/microstate/hook"
+	"github.com/hsblhsn/microstate/state"
+	"github.com/hsblhsn/microstate/watch"
+	"github.com/rotisserie/eris"
+	"github.com/spf13/cobra"
+)
+
+func NewWatchCmd() *cobra.Command {
+	var (
+		logger = NewLogger()
+	)
+	var (
+		kinds      []string
+		train      string
+		run        string
+		asJSON     bool
+		serverURL  string
+		tokenEnv   string
+		cursorFile string
+		interval   time.Duration
+		replay     bool
+		once       bool
+	)
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Print or execute a command for every new release",
+		Long: "Watch the state file, or a server with --server, and print or execute a command for every new release.\n\n" +
+			"The block hash of the last handled release is kept in the cursor file, a restarted watch resumes from it.\n" +
+			"A release is handled again until the command succeeds, so commands must be safe to repeat.\n" +
+			"Commands run with sh -c, the json encoded event on stdin and the environment variables\n" +
+			"MICROSTATE_TAG, MICROSTATE_HASH, MICROSTATE_KIND and MICROSTATE_EVENT.",
+		Example: "  microstate watch --kind ga --train v2 --exec './deploy.sh \"$MICROSTATE_TAG\"'",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filter := watch.Filter{Train: train}
+			if err := filter.Validate(); err != nil {
+				return eris.Wrap(err, "cli: invalid --train")
+			}
+			for _, v := range kinds {
+				kind, err := state.NewReleaseKindFromString(v)
+				if err != nil {
+					return eris.Wrap(err, "cli: invalid --kind")
+				}
+				filter.Kinds = append(filter.Kinds, kind)
+			}
+			var source watch.Source = watch.File(FileName)
+			if serverURL != "" {
+				c := client.New(serverURL)
+				c.Token = os.Getenv(tokenEnv)
+				source = watch.Remote{Client: c}
+			}
+			runner := &hook.Runner{
+				Hooks:  []hook.Hook{{When: hook.Post, Run: run}},
+				Stdout: os.Stdout,
+			}
+			handler := func(e state.Event) error {
+				switch {
+				case run != "":
+					return runner.Run(hook.Post, []state.Event{e})
+				case asJSON:
+					b, err := json.Marshal(e)
+					if err != nil {
+						return err
+					}
+					fmt.Println(string(b))
+				default:
+					fmt.Println(e.Release.String())
+				}
+				return nil
+			}
+			w, err := watch.New(source, handler, cursorFile)
+			if err != nil {
+				return eris.Wrap(err, "cli: could not start watching")
+			}
+			w.Filter = filter
+			w.Interval = interval
+			w.Replay = replay
+			if once {
+				_, err := w.Poll()
+				return err
+			}
+			w.OnError = func(err error) {
+				logger.Warn(err)
+			}
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return w.Run(ctx)
+		},
+	}
+	cmd.Flags().StringArrayVarP(&kinds, "kind", "k", make([]string, 0), "Only handle releases of the given kind. It accepts array of values. (e.g. --kind rc --kind ga)")
+	cmd.Flags().StringVarP(&train, "train", "", "", "Only handle releases of the given major or major.minor version line (e.g. v2 or v2.4)")
+	cmd.Flags().StringVarP(&run, "exec", "e", "", "Command to execute for every release instead of printing it")
+	cmd.Flags().BoolVarP(&asJSON, "json", "", false, "Print the json encoded events")
+	cmd.Flags().StringVarP(&serverURL, "server", "", "", "Watch the ledger served at the given url instead of the state file")
+	cmd.Flags().StringVarP(&tokenEnv, "token-env", "", "MICROSTATE_TOKEN", "Environment variable holding the server token")
+	cmd.Flags().StringVarP(&cursorFile, "cursor", "", WatchCursorFileName, "File keeping the block hash of the last handled release")
+	cmd.Flags().DurationVarP(&interval, "interval", "", watch.DefaultInterval, "Delay between two polls")
+	cmd.Flags().BoolVarP(&replay, "replay", "", false, "Handle the existing releases if there is no cursor yet, instead of starting at the head")
+	cmd.Flags().BoolVarP(&once, "once", "", false, "Handle the new releases once and exit")
+	return cmd
+}
@@ -0,0 +1,275 @@
+// Package client implements the state.Ledger interface over the http api of microstate serve.
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hsblhsn/microstate/server"
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+)
+
+const (
+	// DefaultRetries is the number of times a failed request is retried.
+	DefaultRetries = 3
+	// DefaultBackoff is the delay before the first retry, it doubles on every retry.
+	DefaultBackoff = 200 * time.Millisecond
+)
+
+var (
+	ErrUnexpectedResponse = eris.New("client: unexpected response")
+)
+
+// Client is a client of the http api.
+// Errors reported by the server are wrapped around the sentinel errors of the state
+// and server packages, eris.Cause returns e.g. state.ErrNoRelease.
+type Client struct {
+	// BaseURL of the server. e.g. http://localhost:8080
+	BaseURL string
+	HTTP    *http.Client
+	// Retries is the number of times a failed request is retried.
+	// Reads are retried on network errors, 429 and 5xx responses.
+	// Writes are only retried on 429, 502, 503 and 504 responses,
+	// which are not expected to have reached the ledger.
+	Retries int
+	// Backoff is the delay before the first retry.
+	Backoff time.Duration
+	// Token is sent as bearer token if it is not empty.
+	Token string
+}
+
+var _ state.Ledger = (*Client)(nil)
+
+// New returns a client of the server at the given url.
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		HTTP:    http.DefaultClient,
+		Retries: DefaultRetries,
+		Backoff: DefaultBackoff,
+	}
+}
+
+// Head returns the latest release.
+func (c *Client) Head() (*state.Release, error) {
+	r := new(state.Release)
+	if err := c.do(http.MethodGet, "/head", nil, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// LatestE returns the latest release of the given kind,
+// or a blank release with tag v0.0.0 if there is none.
+// Unlike state.State it has no Latest without an error,
+// a failed request is returned and never mistaken for an empty ledger.
+func (c *Client) LatestE(kind state.ReleaseKind) (*state.Release, error) {
+	r, err := c.Resolve(kind.String())
+	if eris.Is(err, state.ErrNoRelease) {
+		return &state.Release{
+			Kind: kind,
+			Tag:  "v0.0.0",
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetRelease returns the release of the given block hash or hash prefix.
+func (c *Client) GetRelease(hash state.Hash) (*state.Release, error) {
+	return c.Resolve(hash.String())
+}
+
+// Resolve returns the release referenced by a release kind, tag or block hash prefix.
+func (c *Client) Resolve(ref string) (*state.Release, error) {
+	r := new(state.Release)
+	if err := c.do(http.MethodGet, "/releases/"+url.PathEscape(ref), nil, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Releases returns the releases of the given kind, newest first. Zero returns all releases.
+func (c *Client) Releases(kind state.ReleaseKind) ([]*state.Release, error) {
+	path := "/releases"
+	if kind != 0 {
+		path += "?kind=" + url.QueryEscape(kind.String())
+	}
+	releases := make([]*state.Release, 0)
+	if err := c.do(http.MethodGet, path, nil, &releases); err != nil {
+		return nil, err
+	}
+	return releases, nil
+}
+
+// CreateRelease creates the dev release on the server.
+// Like state.State it updates the given release with the created block.
+func (c *Client) CreateRelease(r *state.Release) error {
+	if r == nil {
+		return eris.New("client: release is nil")
+	}
+	if !r.Kind.Is(state.ReleaseKindDev) {
+		return state.ErrReleaseKindIsNotDev
+	}
+	created := new(state.Release)
+	err := c.do(http.MethodPost, "/releases", &server.CreateRequest{
+		Versions:    r.Versions,
+		Annotations: r.Annotations,
+		Notes:       r.Notes,
+		Tag:         r.Tag,
+	}, created)
+	if err != nil {
+		return err
+	}
+	*r = *created
+	return nil
+}
+
+// Promote promotes the latest release of the given kind to the next kind.
+// The options are applied to a blank release, only the annotations and notes they set are sent.
+func (c *Client) Promote(from state.ReleaseKind, opts ...state.ReleaseOption) error {
+	to, err := from.Next()
+	if err != nil {
+		return err
+	}
+	return c.PromoteTo(to, opts...)
+}
+
+// PromoteTo promotes the latest release of the previous kind to the given kind.
+func (c *Client) PromoteTo(to state.ReleaseKind, opts ...state.ReleaseOption) error {
+	_, err := c.promote(to, opts...)
+	return err
+}
+
+func (c *Client) promote(to state.ReleaseKind, opts ...state.ReleaseOption) (*state.Release, error) {
+	scratch := new(state.Release)
+	if err := scratch.Apply(opts...); err != nil {
+		return nil, err
+	}
+	r := new(state.Release)
+	err := c.do(http.MethodPost, "/promote/"+url.PathEscape(to.String()), &server.PromoteRequest{
+		Annotations: scratch.Annotations,
+		Notes:       scratch.Notes,
+	}, r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Rollback removes the head release and returns it.
+func (c *Client) Rollback() (*state.Release, error) {
+	r := new(state.Release)
+	if err := c.do(http.MethodPost, "/rollback", nil, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Verify verifies the ledger on the server.
+// The server re-hashes every release, unless fromCheckpoint is set.
+func (c *Client) Verify(fromCheckpoint bool) (*server.Verification, error) {
+	v := new(server.Verification)
+	if err := c.do(http.MethodGet, fmt.Sprintf("/verify?from_checkpoint=%t", fromCheckpoint), nil, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// do sends the request with retries and decodes the response into v.
+func (c *Client) do(method, path string, body interface{}, v interface{}) error {
+	var b []byte
+	if body != nil {
+		var err error
+		if b, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	backoff := c.Backoff
+	for attempt := 0; ; attempt++ {
+		res, err := c.send(method, path, b)
+		if attempt < c.Retries && c.retryable(method, res, err) {
+			if res != nil {
+				res.Body.Close()
+			}
+			time.Sleep(backoff)
+			backoff *= 2
+			continue
+		}
+		if err != nil {
+			return eris.Wrapf(err, "client: %s %s", method, path)
+		}
+		defer res.Body.Close()
+		return decode(res, v)
+	}
+}
+
+func (c *Client) send(method, path string, body []byte) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, c.BaseURL+path, r)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+// retryable reports whether the request should be retried.
+func (c *Client) retryable(method string, res *http.Response, err error) bool {
+	if method == http.MethodGet {
+		return err != nil || res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+	}
+	if err != nil {
+		return false
+	}
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// decode decodes a successful response into v, or converts an error response to a typed error.
+func decode(res *http.Response, v interface{}) error {
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		if v == nil {
+			return nil
+		}
+		if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+			return eris.Wrapf(ErrUnexpectedResponse, "client: could not decode response: %v", err)
+		}
+		return nil
+	}
+	e := new(server.Error)
+	if err := json.NewDecoder(res.Body).Decode(e); err != nil || e.Code == "" {
+		return eris.Wrapf(ErrUnexpectedResponse, "client: %s", res.Status)
+	}
+	for _, c := range server.Codes {
+		if c.Code == e.Code {
+			return eris.Wrap(c.Err, e.Message)
+		}
+	}
+	return eris.Wrapf(ErrUnexpectedResponse, "client: %s: %s", res.Status, e.Message)
+}
@@ -0,0 +1,136 @@
+package client_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	"github.com/hsblhsn/microstate/client"
+	"github.com/hsblhsn/microstate/server"
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+)
+
+// flaky fails the given number of requests with 503 before passing them to the handler.
+type flaky struct {
+	handler  http.Handler
+	failures int32
+}
+
+func (f *flaky) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if atomic.AddInt32(&f.failures, -1) >= 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	f.handler.ServeHTTP(w, r)
+}
+
+func (f *flaky) fail(n int32) {
+	atomic.StoreInt32(&f.failures, n)
+}
+
+func TestClient(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("Client", func() {
+		var (
+			ts      *httptest.Server
+			handler *flaky
+			c       *client.Client
+		)
+		g.Before(func() {
+			backend, err := server.NewMemoryBackend(nil)
+			g.Assert(err).IsNil()
+			srv := server.New(backend)
+			srv.Prepare = func(s *state.State, r *http.Request) error {
+				s.Clock = state.FixedClock(time.Date(2021, 12, 20, 0, 0, 0, 0, time.UTC))
+				return nil
+			}
+			handler = &flaky{handler: srv}
+			ts = httptest.NewServer(handler)
+			c = client.New(ts.URL)
+			c.Backoff = time.Millisecond
+		})
+		g.After(func() {
+			ts.Close()
+		})
+		g.It("should return the state sentinel errors", func() {
+			_, err := c.Head()
+			g.Assert(eris.Cause(err)).Equal(state.ErrNoRelease)
+			_, err = c.GetRelease("0123456789")
+			g.Assert(eris.Cause(err)).Equal(state.ErrReleaseNotFound)
+			ga, err := c.LatestE(state.ReleaseKindGA)
+			g.Assert(err).IsNil()
+			g.Assert(ga.Tag).Equal("v0.0.0")
+		})
+		g.It("should create releases like the local state", func() {
+			r, err := state.NewRelease(state.ReleaseKindDev, "v1.0.0-dev", state.VersionMap{"user-service": {Version: "3db20cf"}})
+			g.Assert(err).IsNil()
+			g.Assert(c.CreateRelease(r)).IsNil()
+
+			local := state.NewState()
+			local.Clock = state.FixedClock(time.Date(2021, 12, 20, 0, 0, 0, 0, time.UTC))
+			expected, err := state.NewRelease(state.ReleaseKindDev, "v1.0.0-dev", state.VersionMap{"user-service": {Version: "3db20cf"}})
+			g.Assert(err).IsNil()
+			g.Assert(local.CreateRelease(expected)).IsNil()
+			g.Assert(r.BlockHash).Equal(expected.BlockHash)
+
+			head, err := c.Head()
+			g.Assert(err).IsNil()
+			g.Assert(head.BlockHash).Equal(r.BlockHash)
+			found, err := c.GetRelease(r.BlockHash)
+			g.Assert(err).IsNil()
+			g.Assert(found.Tag).Equal("v1.0.0-dev")
+		})
+		g.It("should reject invalid releases", func() {
+			r, err := state.NewRelease(state.ReleaseKindDev, "1.0", state.VersionMap{"user-service": {Version: "3db20cf"}})
+			g.Assert(err).IsNil()
+			g.Assert(eris.Cause(c.CreateRelease(r))).Equal(state.ErrReleaseTagInvalid)
+		})
+		g.It("should promote with annotations and notes", func() {
+			g.Assert(c.Promote(state.ReleaseKindDev, state.WithNotes("first alpha"), state.WithAnnotations(state.Annotations{"ticket": "OPS-1"}))).IsNil()
+			alpha, err := c.LatestE(state.ReleaseKindAlpha)
+			g.Assert(err).IsNil()
+			g.Assert(alpha.Tag).Equal("v1.0.0-alpha")
+			g.Assert(alpha.Notes).Equal("first alpha")
+			g.Assert(alpha.Annotations["ticket"]).Equal("OPS-1")
+		})
+		g.It("should retry failed reads", func() {
+			handler.fail(2)
+			head, err := c.Head()
+			g.Assert(err).IsNil()
+			g.Assert(head.Kind).Equal(state.ReleaseKindAlpha)
+		})
+		g.It("should give up after the retries", func() {
+			handler.fail(client.DefaultRetries + 1)
+			_, err := c.Head()
+			g.Assert(eris.Cause(err)).Equal(client.ErrUnexpectedResponse)
+			handler.fail(client.DefaultRetries + 1)
+			_, err = c.LatestE(state.ReleaseKindAlpha)
+			g.Assert(eris.Cause(err)).Equal(client.ErrUnexpectedResponse)
+		})
+		g.It("should not mistake a failing server for an empty ledger", func() {
+			failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			}))
+			defer failing.Close()
+			fc := client.New(failing.URL)
+			fc.Retries = 0
+			var ledger state.Ledger = fc
+			r, err := ledger.LatestE(state.ReleaseKindAlpha)
+			g.Assert(eris.Cause(err)).Equal(client.ErrUnexpectedResponse)
+			g.Assert(r == nil).IsTrue()
+		})
+		g.It("should be usable as a ledger", func() {
+			var ledger state.Ledger = c
+			head, err := ledger.Head()
+			g.Assert(err).IsNil()
+			g.Assert(head.Tag).Equal("v1.0.0-alpha")
+			alpha, err := ledger.LatestE(state.ReleaseKindAlpha)
+			g.Assert(err).IsNil()
+			g.Assert(alpha.BlockHash).Equal(head.BlockHash)
+		})
+	})
+}
@@ -0,0 +1,117 @@
+// Package cloudevent encodes ledger events as CloudEvents 1.0 in the json structured format
+// and appends them as json lines to a stream.
+//
+// The id of an event is derived from its type and release block hash,
+// so events written on a change and replayed later have the same id.
+package cloudevent
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+)
+
+const (
+	// SpecVersion is the implemented CloudEvents specification version.
+	SpecVersion = "1.0"
+	// TypePrefix is prepended to the state event types. e.g. io.microstate.release.promoted
+	TypePrefix = "io.microstate."
+	// DefaultSource is the source of the events if none is configured.
+	DefaultSource = "microstate"
+	// Stdout is the path that writes the stream to the standard output.
+	Stdout = "-"
+)
+
+var (
+	ErrWriteFailed = eris.New("cloudevent: could not write events")
+)
+
+// Event is a CloudEvent carrying a state.Event as data.
+type Event struct {
+	SpecVersion     string      `json:"specversion"`
+	ID              string      `json:"id"`
+	Source          string      `json:"source"`
+	Type            string      `json:"type"`
+	Subject         string      `json:"subject,omitempty"`
+	Time            time.Time   `json:"time"`
+	DataContentType string      `json:"datacontenttype"`
+	Data            state.Event `json:"data"`
+}
+
+// New returns the CloudEvent of the state event.
+func New(source string, e state.Event) Event {
+	if source == "" {
+		source = DefaultSource
+	}
+	subject := ""
+	if e.Release != nil {
+		subject = e.Release.String()
+	}
+	return Event{
+		SpecVersion:     SpecVersion,
+		ID:              ID(e),
+		Source:          source,
+		Type:            TypePrefix + string(e.Type),
+		Subject:         subject,
+		Time:            e.Time,
+		DataContentType: "application/json",
+		Data:            e,
+	}
+}
+
+// ID returns the id of the event.
+func ID(e state.Event) string {
+	h := sha256.Sum256([]byte(e.Key()))
+	return hex.EncodeToString(h[:16])
+}
+
+// Writer appends events as json lines to a file or the standard output.
+type Writer struct {
+	// Path of the file, or Stdout.
+	Path   string
+	Source string
+	// Out overrides the Path if set.
+	Out io.Writer
+	mu  sync.Mutex
+}
+
+// NewWriter returns a writer appending to the given path.
+func NewWriter(path, source string) *Writer {
+	return &Writer{Path: path, Source: source}
+}
+
+// Write appends the events, one json line each.
+func (w *Writer) Write(events []state.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	out := w.Out
+	if out == nil {
+		if w.Path == Stdout {
+			out = os.Stdout
+		} else {
+			f, err := os.OpenFile(w.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+			if err != nil {
+				return eris.Wrapf(ErrWriteFailed, "cloudevent: could not open %s: %v", w.Path, err)
+			}
+			defer f.Close()
+			out = f
+		}
+	}
+	enc := json.NewEncoder(out)
+	for _, e := range events {
+		if err := enc.Encode(New(w.Source, e)); err != nil {
+			return eris.Wrapf(ErrWriteFailed, "cloudevent: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package cloudevent_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	"github.com/hsblhsn/microstate/cloudevent"
+	"github.com/hsblhsn/microstate/state"
+)
+
+func newState(g *goblin.G) *state.State {
+	s := state.NewState()
+	s.Clock = state.FixedClock(time.Date(2021, 12, 20, 0, 0, 0, 0, time.UTC))
+	r, err := state.NewRelease(state.ReleaseKindDev, "v1.0.0-dev", state.VersionMap{"user-service": {Version: "3db20cf"}})
+	g.Assert(err).IsNil()
+	g.Assert(s.CreateRelease(r)).IsNil()
+	g.Assert(s.Promote(state.ReleaseKindDev)).IsNil()
+	return s
+}
+
+func TestWriter(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("Writer", func() {
+		g.It("should append one cloud event per line", func() {
+			s := newState(g)
+			path := filepath.Join(t.TempDir(), "events.ndjson")
+			w := cloudevent.NewWriter(path, "https://github.com/acme/product")
+			g.Assert(w.Write(s.Events()[:1])).IsNil()
+			g.Assert(w.Write(s.Events()[1:])).IsNil()
+			f, err := os.Open(path)
+			g.Assert(err).IsNil()
+			defer f.Close()
+			lines := make([]map[string]interface{}, 0)
+			scanner := bufio.NewScanner(f)
+			for scanner.Scan() {
+				var v map[string]interface{}
+				g.Assert(json.Unmarshal(scanner.Bytes(), &v)).IsNil()
+				lines = append(lines, v)
+			}
+			g.Assert(len(lines)).Equal(2)
+			g.Assert(lines[0]["specversion"]).Equal("1.0")
+			g.Assert(lines[0]["source"]).Equal("https://github.com/acme/product")
+			g.Assert(lines[0]["type"]).Equal("io.microstate.release.created")
+			g.Assert(lines[1]["type"]).Equal("io.microstate.release.promoted")
+			g.Assert(lines[1]["subject"]).Equal(s.Releases[0].String())
+			g.Assert(lines[1]["time"]).Equal("2021-12-20T00:00:00Z")
+			data := lines[1]["data"].(map[string]interface{})
+			g.Assert(data["from"].(map[string]interface{})["tag"]).Equal("v1.0.0-dev")
+		})
+		g.It("should write the same ids when the chain is replayed", func() {
+			s := newState(g)
+			live, replayed := new(bytes.Buffer), new(bytes.Buffer)
+			g.Assert((&cloudevent.Writer{Out: live}).Write(s.Events())).IsNil()
+			events, err := s.Replay("")
+			g.Assert(err).IsNil()
+			g.Assert((&cloudevent.Writer{Out: replayed}).Write(events)).IsNil()
+			g.Assert(replayed.String()).Equal(live.String())
+		})
+		g.It("should write chain changes that left the ledger empty", func() {
+			s := newState(g)
+			b, err := s.Marshal()
+			g.Assert(err).IsNil()
+			s = state.NewState()
+			g.Assert(s.Load(b)).IsNil()
+			c, err := s.Prune(func(int, *state.Release) bool { return true }, "test")
+			g.Assert(err).IsNil()
+			out := new(bytes.Buffer)
+			g.Assert((&cloudevent.Writer{Out: out}).Write(s.Events())).IsNil()
+			var v map[string]interface{}
+			g.Assert(json.Unmarshal(out.Bytes(), &v)).IsNil()
+			g.Assert(v["type"]).Equal("io.microstate.chain.pruned")
+			g.Assert(v["subject"] == nil).IsTrue()
+			g.Assert(v["data"].(map[string]interface{})["checkpoint"].(map[string]interface{})["hash"]).Equal(c.Hash.String())
+		})
+	})
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"os"
+
 	"github.com/hsblhsn/microstate/cli"
 )
 
@@ -9,6 +11,7 @@ func main() {
 		if r := recover(); r != nil {
 			l := cli.NewLogger()
 			l.Error(r)
+			os.Exit(1)
 		}
 	}()
 	if err := cli.NewRootCmd().Execute(); err != nil {
@@ -0,0 +1,105 @@
+// Package git runs git commands against local repositories.
+package git
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/rotisserie/eris"
+)
+
+var (
+	ErrNotRepository = eris.New("git: not a git repository")
+	ErrTagConflict   = eris.New("git: tag already exists on a different commit")
+)
+
+// Repository is a local git repository.
+type Repository struct {
+	Dir string
+}
+
+// Open returns the repository containing the given directory.
+func Open(dir string) (*Repository, error) {
+	r := &Repository{Dir: dir}
+	root, err := r.Run("rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, eris.Wrapf(ErrNotRepository, "git: %s: %v", dir, err)
+	}
+	r.Dir = root
+	return r, nil
+}
+
+// Run runs a git command in the repository and returns the trimmed output.
+func (r *Repository) Run(args ...string) (string, error) {
+	return r.RunInput(nil, args...)
+}
+
+// RunInput runs a git command with the given stdin.
+func (r *Repository) RunInput(stdin []byte, args ...string) (string, error) {
+	return r.run(nil, stdin, args...)
+}
+
+// run runs a git command with additional environment variables and the given stdin.
+func (r *Repository) run(env []string, stdin []byte, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.Dir
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", eris.Errorf("git %s: %s", strings.Join(args, " "), msg)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// ResolveCommit returns the full sha of the given revision.
+func (r *Repository) ResolveCommit(rev string) (string, error) {
+	return r.Run("rev-parse", "--verify", "--quiet", rev+"^{commit}")
+}
+
+// Tag creates an annotated tag on the given revision.
+// An existing tag pointing to the same commit is left untouched.
+// It returns true if the tag was created.
+func (r *Repository) Tag(name, rev, message string) (bool, error) {
+	commit, err := r.ResolveCommit(rev)
+	if err != nil {
+		return false, eris.Wrapf(err, "git: commit %s not found in %s", rev, r.Dir)
+	}
+	if existing, err := r.ResolveCommit("refs/tags/" + name); err == nil {
+		if existing != commit {
+			return false, eris.Wrapf(ErrTagConflict, "git: tag %s points to %s in %s", name, existing, r.Dir)
+		}
+		return false, nil
+	}
+	if _, err := r.Run("tag", "--annotate", "--message", message, name, commit); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// IsAncestor reports whether the commit a is an ancestor of the commit b.
+func (r *Repository) IsAncestor(a, b string) bool {
+	_, err := r.Run("merge-base", "--is-ancestor", a, b)
+	return err == nil
+}
+
+// IsClean returns true if the path has no uncommitted changes.
+func (r *Repository) IsClean(path string) (bool, error) {
+	out, err := r.Run("status", "--porcelain", "--", path)
+	if err != nil {
+		return false, err
+	}
+	return out == "", nil
+}
@@ -0,0 +1,292 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+)
+
+// DefaultRetries is the number of times an operation is replayed when the branch moved.
+const DefaultRetries = 3
+
+// zeroCommit makes update-ref fail if the ref already exists.
+const zeroCommit = "0000000000000000000000000000000000000000"
+
+var (
+	ErrBranchMoved = eris.New("git: branch moved")
+)
+
+// Operation changes the state and returns the commit message of the change.
+type Operation func(s *state.State) (string, error)
+
+// Store keeps the state file in a git branch and commits every change to it.
+// Commits are created with plumbing commands, the branch does not need to be checked out.
+type Store struct {
+	Repo *Repository
+	// Path of the state file relative to the repository root.
+	Path string
+	// Branch receiving the commits.
+	Branch string
+	// Remote is fetched before and pushed after every change. Empty keeps the branch local.
+	Remote string
+	// Retries is the number of times an operation is replayed when the branch moved.
+	// Zero uses DefaultRetries.
+	Retries int
+}
+
+// NewStore returns the store of the given state file.
+// The branch defaults to the checked out branch of the repository holding the file.
+func NewStore(file, branch, remote string) (*Store, error) {
+	dir, err := filepath.Abs(filepath.Dir(file))
+	if err != nil {
+		return nil, err
+	}
+	repo, err := Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := (&Repository{Dir: dir}).Run("rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, err
+	}
+	if branch == "" {
+		branch, err = repo.Run("symbolic-ref", "--short", "HEAD")
+		if err != nil {
+			return nil, eris.Wrap(err, "git: HEAD is detached, configure the branch of the store")
+		}
+	}
+	return &Store{
+		Repo:   repo,
+		Path:   prefix + filepath.Base(file),
+		Branch: branch,
+		Remote: remote,
+	}, nil
+}
+
+// ref returns the ref the state is loaded from.
+func (st *Store) ref() string {
+	if st.Remote != "" {
+		return fmt.Sprintf("refs/remotes/%s/%s", st.Remote, st.Branch)
+	}
+	return "refs/heads/" + st.Branch
+}
+
+// Load returns the state at the tip of the branch and the tip commit.
+// It returns an empty state and commit if the branch or the state file does not exist yet.
+func (st *Store) Load() (*state.State, string, error) {
+	if st.Remote != "" {
+		refspec := fmt.Sprintf("+refs/heads/%s:%s", st.Branch, st.ref())
+		if _, err := st.Repo.Run("fetch", "--quiet", st.Remote, refspec); err != nil && !strings.Contains(err.Error(), "couldn't find remote ref") {
+			return nil, "", eris.Wrapf(err, "git: could not fetch %s", st.Remote)
+		}
+	}
+	s := state.NewState()
+	tip, err := st.Repo.ResolveCommit(st.ref())
+	if err != nil {
+		return s, "", nil
+	}
+	object := tip + ":" + st.Path
+	if _, err := st.Repo.Run("cat-file", "-e", object); err != nil {
+		return s, tip, nil
+	}
+	b, err := st.Repo.Run("cat-file", "blob", object)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.Load([]byte(b)); err != nil {
+		return nil, "", eris.Wrapf(err, "git: could not load %s", object)
+	}
+	return s, tip, nil
+}
+
+// Apply loads the state from the branch, applies the operation and commits the result.
+// If the branch moved in the meantime, the operation is replayed on the new tip of the branch.
+// An operation that leaves the state unchanged creates no commit.
+// The working tree copy of the state file is updated if the branch is checked out.
+func (st *Store) Apply(op Operation) (*state.State, error) {
+	retries := st.Retries
+	if retries <= 0 {
+		retries = DefaultRetries
+	}
+	for attempt := 0; ; attempt++ {
+		s, base, err := st.Load()
+		if err != nil {
+			return nil, err
+		}
+		before, err := s.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		message, err := op(s)
+		if err != nil {
+			return nil, err
+		}
+		after, err := s.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(before, after) {
+			return s, nil
+		}
+		err = st.commit(s, base, message)
+		if eris.Is(err, ErrBranchMoved) && attempt < retries {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+}
+
+// commit commits the state on top of the base commit and moves the branch to it.
+// It fails with ErrBranchMoved if the branch does not point to the base commit anymore.
+func (st *Store) commit(s *state.State, base, message string) error {
+	b, err := s.Marshal()
+	if err != nil {
+		return err
+	}
+	blob, err := st.Repo.RunInput(b, "hash-object", "-w", "--stdin")
+	if err != nil {
+		return err
+	}
+	tree, err := st.tree(base, blob)
+	if err != nil {
+		return err
+	}
+	args := []string{"commit-tree", tree, "-m", message}
+	if base != "" {
+		args = append(args, "-p", base)
+	}
+	commit, err := st.Repo.Run(args...)
+	if err != nil {
+		return err
+	}
+	if err := st.update(base, commit); err != nil {
+		return err
+	}
+	return st.checkout(b, st.mode(commit), commit)
+}
+
+// tree writes the tree of the base commit with the state file replaced by the given blob.
+func (st *Store) tree(base, blob string) (string, error) {
+	f, err := os.CreateTemp("", "microstate-index-*")
+	if err != nil {
+		return "", err
+	}
+	index := f.Name()
+	f.Close()
+	// git refuses to read an empty index file
+	os.Remove(index)
+	defer os.Remove(index)
+	env := []string{"GIT_INDEX_FILE=" + index}
+	if base != "" {
+		if _, err := st.Repo.run(env, nil, "read-tree", base); err != nil {
+			return "", err
+		}
+	}
+	if _, err := st.Repo.run(env, nil, "update-index", "--add", "--cacheinfo", st.mode(base)+","+blob+","+st.Path); err != nil {
+		return "", err
+	}
+	return st.Repo.run(env, nil, "write-tree")
+}
+
+// mode returns the file mode of the state file in the base commit.
+// New state files are not executable.
+func (st *Store) mode(base string) string {
+	if base != "" {
+		out, err := st.Repo.Run("ls-tree", base, "--", st.Path)
+		if fields := strings.Fields(out); err == nil && len(fields) != 0 {
+			return fields[0]
+		}
+	}
+	return "100644"
+}
+
+// update moves the branch from the base commit to the new commit.
+func (st *Store) update(base, commit string) error {
+	local := "refs/heads/" + st.Branch
+	if st.Remote == "" {
+		old := base
+		if old == "" {
+			old = zeroCommit
+		}
+		if _, err := st.Repo.Run("update-ref", "-m", "microstate", local, commit, old); err != nil {
+			if tip, _ := st.Repo.ResolveCommit(local); tip != base {
+				return eris.Wrapf(ErrBranchMoved, "git: %s moved to %s", st.Branch, tip)
+			}
+			return err
+		}
+		return nil
+	}
+	if _, err := st.Repo.Run("push", "--quiet", st.Remote, commit+":"+local); err != nil {
+		if strings.Contains(err.Error(), "rejected") {
+			return eris.Wrapf(ErrBranchMoved, "git: %s/%s moved: %v", st.Remote, st.Branch, err)
+		}
+		return eris.Wrapf(err, "git: could not push to %s", st.Remote)
+	}
+	if _, err := st.Repo.Run("update-ref", st.ref(), commit); err != nil {
+		return err
+	}
+	// fast-forward the local branch, diverged local branches are left to the user
+	tip, err := st.Repo.ResolveCommit(local)
+	if err == nil && !st.Repo.IsAncestor(tip, commit) {
+		return nil
+	}
+	if st.checkedOut() {
+		// moving the ref alone would leave the index and working tree behind,
+		// a branch with conflicting local changes is left to the user
+		st.Repo.Run("merge", "--quiet", "--ff-only", commit)
+		return nil
+	}
+	_, err = st.Repo.Run("update-ref", "-m", "microstate", local, commit)
+	return err
+}
+
+// checkedOut reports whether the branch is checked out in the repository.
+func (st *Store) checkedOut() bool {
+	head, err := st.Repo.Run("symbolic-ref", "--quiet", "HEAD")
+	return err == nil && head == "refs/heads/"+st.Branch
+}
+
+// checkout updates the working tree and index copy of the state file
+// if the branch is checked out and points to the commit.
+func (st *Store) checkout(b []byte, mode, commit string) error {
+	if !st.checkedOut() {
+		return nil
+	}
+	if tip, err := st.Repo.ResolveCommit("refs/heads/" + st.Branch); err != nil || tip != commit {
+		return nil
+	}
+	perm := os.FileMode(0644)
+	if mode == "100755" {
+		perm = 0755
+	}
+	path := filepath.Join(st.Repo.Dir, st.Path)
+	if err := os.WriteFile(path, b, perm); err != nil {
+		return err
+	}
+	if err := os.Chmod(path, perm); err != nil {
+		return err
+	}
+	_, err := st.Repo.Run("reset", "--quiet", "--", st.Path)
+	return err
+}
+
+// CommitMessage returns the structured commit message of an operation on the release.
+// The details are recorded as git trailers. A nil release describes an operation that left the ledger empty.
+func CommitMessage(operation string, r *state.Release) string {
+	if r == nil {
+		return fmt.Sprintf("microstate: %s, ledger is empty\n\nMicrostate-Operation: %s\n", operation, operation)
+	}
+	return fmt.Sprintf(
+		"microstate: %s %s %s\n\nMicrostate-Operation: %s\nMicrostate-Kind: %s\nMicrostate-Tag: %s\nMicrostate-Block: %s\n",
+		operation, r.Kind, r.Tag,
+		operation, r.Kind, r.Tag, r.BlockHash,
+	)
+}
@@ -0,0 +1,147 @@
+package git_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/franela/goblin"
+	"github.com/hsblhsn/microstate/git"
+	"github.com/hsblhsn/microstate/state"
+)
+
+// publish returns an operation creating a dev release with the given tag.
+func publish(tag string) git.Operation {
+	return func(s *state.State) (string, error) {
+		r, err := state.NewRelease(state.ReleaseKindDev, tag, state.VersionMap{"user-service": {Version: tag}})
+		if err != nil {
+			return "", err
+		}
+		if err := s.CreateRelease(r); err != nil {
+			return "", err
+		}
+		return git.CommitMessage("publish", s.Releases[0]), nil
+	}
+}
+
+func TestStore(t *testing.T) {
+	g := goblin.Goblin(t)
+	for k, v := range map[string]string{
+		"GIT_AUTHOR_NAME":     "test",
+		"GIT_AUTHOR_EMAIL":    "test@example.com",
+		"GIT_COMMITTER_NAME":  "test",
+		"GIT_COMMITTER_EMAIL": "test@example.com",
+		"GIT_CONFIG_GLOBAL":   os.DevNull,
+	} {
+		t.Setenv(k, v)
+	}
+	g.Describe("Store", func() {
+		g.It("should commit every change", func() {
+			dir := t.TempDir()
+			initRepo(g, dir)
+			file := filepath.Join(dir, ".state.json")
+			store, err := git.NewStore(file, "", "")
+			g.Assert(err).IsNil()
+			g.Assert(store.Path).Equal(".state.json")
+
+			s, err := store.Apply(publish("v0.0.1-dev"))
+			g.Assert(err).IsNil()
+			g.Assert(len(s.Releases)).Equal(1)
+			message, err := store.Repo.Run("log", "-1", "--format=%B")
+			g.Assert(err).IsNil()
+			g.Assert(strings.HasPrefix(message, "microstate: publish dev v0.0.1-dev")).IsTrue()
+			g.Assert(strings.Contains(message, "Microstate-Block: "+s.Releases[0].BlockHash.String())).IsTrue()
+
+			clean, err := store.Repo.IsClean(file)
+			g.Assert(err).IsNil()
+			g.Assert(clean).IsTrue()
+
+			loaded, _, err := store.Load()
+			g.Assert(err).IsNil()
+			g.Assert(loaded.Releases[0].BlockHash).Equal(s.Releases[0].BlockHash)
+		})
+		g.It("should replay the operation if the branch moved", func() {
+			dir := t.TempDir()
+			initRepo(g, dir)
+			store, err := git.NewStore(filepath.Join(dir, ".state.json"), "", "")
+			g.Assert(err).IsNil()
+			attempts := 0
+			s, err := store.Apply(func(s *state.State) (string, error) {
+				attempts++
+				if attempts == 1 {
+					// another writer publishes while the operation runs
+					other := *store
+					_, err := other.Apply(publish("v0.0.1-dev"))
+					g.Assert(err).IsNil()
+				}
+				return publish("v0.0.2-dev")(s)
+			})
+			g.Assert(err).IsNil()
+			g.Assert(attempts).Equal(2)
+			g.Assert(len(s.Releases)).Equal(2)
+			g.Assert(s.Releases[0].Tag).Equal("v0.0.2-dev")
+			g.Assert(s.Validate()).IsNil()
+			count, err := store.Repo.Run("rev-list", "--count", "HEAD")
+			g.Assert(err).IsNil()
+			g.Assert(count).Equal("3")
+		})
+		g.It("should push to the remote", func() {
+			remote, dir := t.TempDir(), t.TempDir()
+			initRepo(g, remote)
+			initRepo(g, dir)
+			repo, err := git.Open(dir)
+			g.Assert(err).IsNil()
+			_, err = repo.Run("remote", "add", "origin", remote)
+			g.Assert(err).IsNil()
+			_, err = repo.Run("checkout", "--quiet", "-b", "releases")
+			g.Assert(err).IsNil()
+			store, err := git.NewStore(filepath.Join(dir, ".state.json"), "releases", "origin")
+			g.Assert(err).IsNil()
+			s, err := store.Apply(publish("v0.0.1-dev"))
+			g.Assert(err).IsNil()
+			pushed, err := (&git.Repository{Dir: remote}).Run("show", "releases:.state.json")
+			g.Assert(err).IsNil()
+			g.Assert(strings.Contains(pushed, s.Releases[0].BlockHash.String())).IsTrue()
+		})
+		g.It("should fast-forward the checked out branch", func() {
+			remote, dir := t.TempDir(), t.TempDir()
+			initRepo(g, remote)
+			upstream := &git.Repository{Dir: remote}
+			_, err := upstream.Run("checkout", "--quiet", "-b", "releases")
+			g.Assert(err).IsNil()
+			_, err = (&git.Repository{Dir: dir}).Run("clone", "--quiet", "--branch", "releases", remote, ".")
+			g.Assert(err).IsNil()
+			g.Assert(os.WriteFile(filepath.Join(remote, "README.md"), []byte("releases\n"), 0644)).IsNil()
+			_, err = upstream.Run("add", "README.md")
+			g.Assert(err).IsNil()
+			_, err = upstream.Run("commit", "--quiet", "--message", "readme")
+			g.Assert(err).IsNil()
+			_, err = upstream.Run("checkout", "--quiet", "--detach")
+			g.Assert(err).IsNil()
+
+			store, err := git.NewStore(filepath.Join(dir, ".state.json"), "releases", "origin")
+			g.Assert(err).IsNil()
+			_, err = store.Apply(publish("v0.0.1-dev"))
+			g.Assert(err).IsNil()
+			status, err := store.Repo.Run("status", "--porcelain")
+			g.Assert(err).IsNil()
+			g.Assert(status).Equal("")
+			_, err = os.Stat(filepath.Join(dir, "README.md"))
+			g.Assert(err).IsNil()
+		})
+		g.It("should not commit an unchanged state", func() {
+			dir := t.TempDir()
+			initRepo(g, dir)
+			store, err := git.NewStore(filepath.Join(dir, ".state.json"), "", "")
+			g.Assert(err).IsNil()
+			_, err = store.Apply(func(s *state.State) (string, error) {
+				return "nothing", nil
+			})
+			g.Assert(err).IsNil()
+			count, err := store.Repo.Run("rev-list", "--count", "HEAD")
+			g.Assert(err).IsNil()
+			g.Assert(count).Equal("1")
+		})
+	})
+}
@@ -0,0 +1,201 @@
+package git
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+)
+
+// DefaultTagPrefix is the prefix of the tags created in service repositories.
+const DefaultTagPrefix = "product/"
+
+var (
+	ErrLedgerDirty         = eris.New("git: state file has uncommitted changes")
+	ErrReleaseNotCommitted = eris.New("git: release is not committed to the ledger")
+)
+
+// Tagger tags the repositories of the services and the ledger with a release.
+type Tagger struct {
+	// Repositories map service names to local clone paths.
+	// The names are normalized with Normalization. Services without a repository are skipped.
+	Repositories map[string]string
+	// Normalization is applied to the service names of Repositories.
+	Normalization state.Normalization
+	// Prefix of the service repository tags. e.g. product/ tags product/v1.2.0
+	Prefix string
+	// Ledger is the path of the state file.
+	// The repository holding it is tagged with the release tag. Empty skips the ledger.
+	Ledger string
+	// LedgerBranch is the branch the state file is committed to. Empty uses HEAD.
+	LedgerBranch string
+}
+
+// ledger is the repository of the state file and the commit to tag.
+type ledger struct {
+	repo   *Repository
+	commit string
+}
+
+// TagResult is a tag created or found by the tagger.
+type TagResult struct {
+	Service    string
+	Repository string
+	Tag        string
+	Commit     string
+	// Created is false if the tag already existed on the commit.
+	Created bool
+}
+
+func (t TagResult) String() string {
+	name := t.Service
+	if name == "" {
+		name = "ledger"
+	}
+	return fmt.Sprintf("%s: %s -> %.12s", name, t.Tag, t.Commit)
+}
+
+// TagName returns the tag name of the release in service repositories.
+func (t *Tagger) TagName(r *state.Release) string {
+	prefix := t.Prefix
+	if prefix == "" {
+		prefix = DefaultTagPrefix
+	}
+	return prefix + r.Tag
+}
+
+// Tag creates annotated tags for the release.
+// Service repositories are tagged on the commit of the recorded sha,
+// or on the primary version if the service has no sha.
+// The ledger repository is tagged on the oldest commit whose state file has the release as head,
+// see ledgerCommit.
+// Existing tags on the same commits are kept, so tagging a release twice is a no-op.
+func (t *Tagger) Tag(r *state.Release) ([]TagResult, error) {
+	ledger, err := t.openLedger(r)
+	if err != nil {
+		return nil, err
+	}
+	repositories := make(map[string]string, len(t.Repositories))
+	for svc, dir := range t.Repositories {
+		repositories[t.Normalization.NormalizeService(svc)] = dir
+	}
+	results := make([]TagResult, 0)
+	message := fmt.Sprintf("%s release %s\n\nblock: %s", r.Kind, r.Tag, r.BlockHash)
+	for _, svc := range r.Versions.Services() {
+		dir, ok := repositories[svc]
+		if !ok {
+			continue
+		}
+		v := r.Versions[svc]
+		rev := v.SHA
+		if rev == "" {
+			rev = v.Primary()
+		}
+		repo, err := Open(dir)
+		if err != nil {
+			return results, eris.Wrapf(err, "git: could not open repository of %s", svc)
+		}
+		result, err := tag(repo, t.TagName(r), rev, message)
+		if err != nil {
+			return results, eris.Wrapf(err, "git: could not tag %s", svc)
+		}
+		result.Service = svc
+		results = append(results, result)
+	}
+	if ledger == nil {
+		return results, nil
+	}
+	result, err := tag(ledger.repo, r.Tag, ledger.commit, message)
+	if err != nil {
+		return results, eris.Wrap(err, "git: could not tag ledger")
+	}
+	return append(results, result), nil
+}
+
+func tag(repo *Repository, name, rev, message string) (TagResult, error) {
+	created, err := repo.Tag(name, rev, message)
+	if err != nil {
+		return TagResult{}, err
+	}
+	commit, err := repo.ResolveCommit("refs/tags/" + name)
+	if err != nil {
+		return TagResult{}, err
+	}
+	return TagResult{
+		Repository: repo.Dir,
+		Tag:        name,
+		Commit:     commit,
+		Created:    created,
+	}, nil
+}
+
+// openLedger returns the ledger repository and the commit of the release, or nil if the ledger is not tagged.
+func (t *Tagger) openLedger(r *state.Release) (*ledger, error) {
+	if t.Ledger == "" {
+		return nil, nil
+	}
+	path, err := filepath.Abs(t.Ledger)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := Open(filepath.Dir(path))
+	if err != nil {
+		return nil, eris.Wrap(err, "git: could not open ledger repository")
+	}
+	prefix, err := (&Repository{Dir: filepath.Dir(path)}).Run("rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, err
+	}
+	commit, err := t.ledgerCommit(repo, prefix+filepath.Base(path), r)
+	if err != nil {
+		return nil, err
+	}
+	return &ledger{repo: repo, commit: commit}, nil
+}
+
+// ledgerCommit returns the oldest commit of the ledger branch whose state file has the release as head.
+// The commits of the git store are found by their Microstate-Block trailer,
+// other commits are only found if the release is the head of the state file at the tip of the branch.
+// It fails with ErrLedgerDirty if the release is not committed yet.
+func (t *Tagger) ledgerCommit(repo *Repository, path string, r *state.Release) (string, error) {
+	rev := t.LedgerBranch
+	if rev == "" {
+		rev = "HEAD"
+	}
+	out, err := repo.Run("log", "--format=%H", "--reverse", "--fixed-strings", "--grep", "Microstate-Block: "+r.BlockHash.String(), rev, "--")
+	if err != nil {
+		return "", eris.Wrapf(err, "git: could not search the commits of %s", rev)
+	}
+	// an existing tag is kept if it points to a commit of the release
+	candidates := append([]string{"refs/tags/" + r.Tag}, strings.Fields(out)...)
+	candidates = append(candidates, rev)
+	for _, commit := range candidates {
+		if !r.BlockHash.IsEmpty() && headOf(repo, commit, path) == r.BlockHash {
+			return repo.ResolveCommit(commit)
+		}
+	}
+	clean, err := repo.IsClean(path)
+	if err != nil {
+		return "", err
+	}
+	if !clean && t.LedgerBranch == "" {
+		return "", eris.Wrapf(ErrLedgerDirty, "git: commit %s before tagging %s", t.Ledger, r.Tag)
+	}
+	return "", eris.Wrapf(ErrReleaseNotCommitted, "git: no commit of %s has %s as head of %s", rev, r.Tag, path)
+}
+
+// headOf returns the block hash of the head release of the state file at the commit.
+// It returns an empty hash if the file does not exist or has no releases.
+func headOf(repo *Repository, commit, path string) state.Hash {
+	b, err := repo.Run("cat-file", "blob", commit+":"+path)
+	if err != nil {
+		return ""
+	}
+	s := state.NewState()
+	if err := s.Load([]byte(b)); err != nil || len(s.Releases) == 0 {
+		return ""
+	}
+	return s.Releases[0].BlockHash
+}
@@ -0,0 +1,140 @@
+package git_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/franela/goblin"
+	"github.com/hsblhsn/microstate/git"
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+)
+
+// initRepo creates a repository with a single commit and returns the commit sha.
+func initRepo(g *goblin.G, dir string) string {
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"commit", "--quiet", "--allow-empty", "--message", "init"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		g.Assert(err == nil).IsTrue(string(out))
+	}
+	repo, err := git.Open(dir)
+	g.Assert(err).IsNil()
+	sha, err := repo.ResolveCommit("HEAD")
+	g.Assert(err).IsNil()
+	return sha
+}
+
+func TestTagger(t *testing.T) {
+	g := goblin.Goblin(t)
+	for k, v := range map[string]string{
+		"GIT_AUTHOR_NAME":     "test",
+		"GIT_AUTHOR_EMAIL":    "test@example.com",
+		"GIT_COMMITTER_NAME":  "test",
+		"GIT_COMMITTER_EMAIL": "test@example.com",
+		"GIT_CONFIG_GLOBAL":   os.DevNull,
+	} {
+		t.Setenv(k, v)
+	}
+	g.Describe("Tagger", func() {
+		g.It("should tag the service commits and the ledger", func() {
+			svc, ledger := t.TempDir(), t.TempDir()
+			sha := initRepo(g, svc)
+			s := state.NewState()
+			r, err := state.NewRelease(state.ReleaseKindDev, "v1.2.0-dev", state.VersionMap{
+				"user-service":  {Version: "v1.2.0", SHA: sha[:7]},
+				"other-service": {Version: "v2.0.0"},
+			})
+			g.Assert(err).IsNil()
+			g.Assert(s.CreateRelease(r)).IsNil()
+			g.Assert(s.Export(filepath.Join(ledger, ".state.json"))).IsNil()
+			tagger := &git.Tagger{
+				Repositories: map[string]string{"User-Service": svc},
+				Ledger:       filepath.Join(ledger, ".state.json"),
+			}
+			initRepo(g, ledger)
+			_, err = tagger.Tag(r)
+			g.Assert(eris.Cause(err)).Equal(git.ErrLedgerDirty)
+
+			repo, err := git.Open(ledger)
+			g.Assert(err).IsNil()
+			_, err = repo.Run("add", ".state.json")
+			g.Assert(err).IsNil()
+			_, err = repo.Run("commit", "--quiet", "--message", "release")
+			g.Assert(err).IsNil()
+			head, err := repo.ResolveCommit("HEAD")
+			g.Assert(err).IsNil()
+
+			results, err := tagger.Tag(r)
+			g.Assert(err).IsNil()
+			g.Assert(len(results)).Equal(2)
+			g.Assert(results[0].Service).Equal("user-service")
+			g.Assert(results[0].Tag).Equal("product/v1.2.0-dev")
+			g.Assert(results[0].Commit).Equal(sha)
+			g.Assert(results[0].Created).IsTrue()
+			g.Assert(results[1].Tag).Equal("v1.2.0-dev")
+			g.Assert(results[1].Commit).Equal(head)
+
+			next, err := state.NewRelease(state.ReleaseKindDev, "v1.2.1-dev", state.VersionMap{"user-service": {Version: "v1.2.1"}})
+			g.Assert(err).IsNil()
+			g.Assert(s.CreateRelease(next)).IsNil()
+			g.Assert(s.Export(filepath.Join(ledger, ".state.json"))).IsNil()
+			_, err = repo.Run("commit", "--quiet", "--all", "--message", "next")
+			g.Assert(err).IsNil()
+
+			results, err = tagger.Tag(r)
+			g.Assert(err).IsNil()
+			g.Assert(results[0].Created).IsFalse()
+			g.Assert(results[1].Commit).Equal(head)
+
+			// without the git store trailer, only the head release can be tagged
+			r.Tag = "v1.2.0-dev.1"
+			_, err = tagger.Tag(r)
+			g.Assert(eris.Cause(err)).Equal(git.ErrReleaseNotCommitted)
+		})
+		g.It("should tag the ledger on the commit of the release", func() {
+			ledger := t.TempDir()
+			initRepo(g, ledger)
+			store, err := git.NewStore(filepath.Join(ledger, ".state.json"), "", "")
+			g.Assert(err).IsNil()
+			s, err := store.Apply(publish("v0.0.1-dev"))
+			g.Assert(err).IsNil()
+			first, err := store.Repo.ResolveCommit("HEAD")
+			g.Assert(err).IsNil()
+			_, err = store.Apply(publish("v0.0.2-dev"))
+			g.Assert(err).IsNil()
+
+			tagger := &git.Tagger{Ledger: filepath.Join(ledger, ".state.json")}
+			results, err := tagger.Tag(s.Releases[0])
+			g.Assert(err).IsNil()
+			g.Assert(results[0].Commit).Equal(first)
+		})
+		g.It("should reject a tag on a different commit", func() {
+			svc := t.TempDir()
+			initRepo(g, svc)
+			repo, err := git.Open(svc)
+			g.Assert(err).IsNil()
+			_, err = repo.Tag("product/v1.0.0", "HEAD", "v1.0.0")
+			g.Assert(err).IsNil()
+			_, err = repo.Run("commit", "--allow-empty", "--message", "next")
+			g.Assert(err).IsNil()
+			_, err = repo.Tag("product/v1.0.0", "HEAD", "v1.0.0")
+			g.Assert(eris.Cause(err)).Equal(git.ErrTagConflict)
+		})
+		g.It("should fail on unknown commits", func() {
+			svc := t.TempDir()
+			initRepo(g, svc)
+			tagger := &git.Tagger{Repositories: map[string]string{"user-service": svc}}
+			_, err := tagger.Tag(&state.Release{
+				Tag:      "v1.0.0",
+				Versions: state.VersionMap{"user-service": {Version: "deadbeef"}},
+			})
+			g.Assert(err == nil).IsFalse()
+		})
+	})
+}
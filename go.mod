@@ -8,6 +8,7 @@ require (
 	github.com/logrusorgru/aurora/v3 v3.0.0
 	github.com/rotisserie/eris v0.5.1
 	github.com/spf13/cobra v1.3.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
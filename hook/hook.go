@@ -0,0 +1,245 @@
+// Package hook runs local commands before and after ledger changes.
+//
+// Hooks run with sh -c. The json encoded state.Event of the change is passed on stdin
+// and the release is described by the environment variables
+// MICROSTATE_TAG, MICROSTATE_HASH, MICROSTATE_KIND, MICROSTATE_OPERATION, MICROSTATE_EVENT and MICROSTATE_PHASE.
+// The release variables are empty for chain changes that left the ledger empty.
+package hook
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+)
+
+var (
+	ErrHookFailed  = eris.New("hook: hook failed")
+	ErrHookInvalid = eris.New("hook: hook is invalid")
+)
+
+// Phase is the time a hook runs at.
+type Phase string
+
+const (
+	// Pre hooks run after the change is made and before it is saved. A failing pre hook aborts the change.
+	Pre Phase = "pre"
+	// Post hooks run after the change is saved.
+	Post Phase = "post"
+)
+
+// Operations of the ledger hooks can be bound to.
+const (
+	OperationPublish  = "publish"
+	OperationRollback = "rollback"
+	OperationPrune    = "prune"
+	OperationMigrate  = "migrate"
+	OperationArchive  = "archive"
+	OperationMerge    = "merge"
+)
+
+// Operation returns the operation that emitted the event.
+func Operation(t state.EventType) string {
+	switch t {
+	case state.EventReleaseRolledBack:
+		return OperationRollback
+	case state.EventChainPruned:
+		return OperationPrune
+	case state.EventChainRewritten:
+		return OperationMigrate
+	case state.EventChainArchived:
+		return OperationArchive
+	case state.EventChainMerged:
+		return OperationMerge
+	}
+	return OperationPublish
+}
+
+// Hook is a command bound to an operation and a release kind.
+type Hook struct {
+	When Phase `json:"when"`
+	// Operation is publish, rollback, prune, migrate, archive or merge. Empty matches every operation.
+	Operation string `json:"operation,omitempty"`
+	// Kind of the published or removed release, or of the head release after a chain change.
+	// Empty matches every kind.
+	Kind string `json:"kind,omitempty"`
+	// Run is the shell command.
+	Run string `json:"run"`
+}
+
+// Validate returns an error if the hook is invalid.
+func (h Hook) Validate() error {
+	if h.When != Pre && h.When != Post {
+		return eris.Wrapf(ErrHookInvalid, "hook: unknown phase %q of %q", h.When, h.Run)
+	}
+	switch h.Operation {
+	case "", OperationPublish, OperationRollback, OperationPrune, OperationMigrate, OperationArchive, OperationMerge:
+	default:
+		return eris.Wrapf(ErrHookInvalid, "hook: unknown operation %q of %q", h.Operation, h.Run)
+	}
+	if h.Kind != "" {
+		if _, err := state.NewReleaseKindFromString(h.Kind); err != nil {
+			return eris.Wrapf(ErrHookInvalid, "hook: unknown kind %q of %q", h.Kind, h.Run)
+		}
+	}
+	if strings.TrimSpace(h.Run) == "" {
+		return eris.Wrap(ErrHookInvalid, "hook: command is empty")
+	}
+	return nil
+}
+
+// Matches reports whether the hook runs in the phase for the event.
+func (h Hook) Matches(phase Phase, e state.Event) bool {
+	if h.When != phase {
+		return false
+	}
+	if h.Operation != "" && h.Operation != Operation(e.Type) {
+		return false
+	}
+	return h.Kind == "" || h.Kind == kind(e)
+}
+
+// kind returns the kind of the event release.
+// It returns an empty string if the event has no release of a valid kind,
+// e.g. a chain change that left the ledger empty, which matches no kind filter.
+func kind(e state.Event) string {
+	if e.Release == nil || !e.Release.Kind.IsValid() {
+		return ""
+	}
+	return e.Release.Kind.String()
+}
+
+// describe returns the release of the event for messages, or the event type if it has no release.
+func describe(e state.Event) string {
+	if kind(e) == "" {
+		return string(e.Type)
+	}
+	return e.Release.String()
+}
+
+// Runner runs hooks.
+type Runner struct {
+	Hooks []Hook
+	// Stdout and Stderr of the hooks. Default to os.Stderr,
+	// the stdout of microstate is kept for command output.
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Run runs the hooks of the phase matching the events, in order.
+// Pre hooks stop at the first failure, post hooks run regardless and report all failures.
+func (r *Runner) Run(phase Phase, events []state.Event) error {
+	failed := make([]error, 0)
+	for _, e := range events {
+		for _, h := range r.Hooks {
+			if !h.Matches(phase, e) {
+				continue
+			}
+			if err := r.run(h, e); err != nil {
+				if phase == Pre {
+					return err
+				}
+				failed = append(failed, err)
+			}
+		}
+	}
+	switch len(failed) {
+	case 0:
+		return nil
+	case 1:
+		return failed[0]
+	}
+	messages := make([]string, 0, len(failed))
+	for _, err := range failed {
+		messages = append(messages, err.Error())
+	}
+	return eris.Wrap(ErrHookFailed, strings.Join(messages, "; "))
+}
+
+// Once runs the hooks of a change that may be replayed, e.g. by the git store when its branch moved.
+// A replayed change runs the hooks again only if it describes different releases.
+type Once struct {
+	Runner *Runner
+	ran    bool
+	key    string
+}
+
+// Run runs the hooks of the phase for the events, unless they already ran for an equal change.
+func (o *Once) Run(phase Phase, events []state.Event) error {
+	key, err := changeKey(events)
+	if err != nil {
+		return err
+	}
+	if o.ran && key == o.key {
+		return nil
+	}
+	if err := o.Runner.Run(phase, events); err != nil {
+		return err
+	}
+	o.ran, o.key = true, key
+	return nil
+}
+
+// changeKey describes the releases of the events, ignoring their block hashes and times.
+func changeKey(events []state.Event) (string, error) {
+	type release struct {
+		Type        state.EventType
+		Kind        string
+		Tag         string
+		Versions    state.VersionMap
+		Annotations state.Annotations
+		Notes       string
+	}
+	releases := make([]release, 0, len(events))
+	for _, e := range events {
+		v := release{Type: e.Type, Kind: kind(e)}
+		if e.Release != nil {
+			v.Tag = e.Release.Tag
+			v.Versions = e.Release.Versions
+			v.Annotations = e.Release.Annotations
+			v.Notes = e.Release.Notes
+		}
+		releases = append(releases, v)
+	}
+	b, err := json.Marshal(releases)
+	return string(b), err
+}
+
+func (r *Runner) run(h Hook, e state.Event) error {
+	stdin, err := json.Marshal(&e)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command("sh", "-c", h.Run)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = orStderr(r.Stdout)
+	cmd.Stderr = orStderr(r.Stderr)
+	var tag, hash string
+	if e.Release != nil {
+		tag, hash = e.Release.Tag, e.Release.BlockHash.String()
+	}
+	cmd.Env = append(os.Environ(),
+		"MICROSTATE_TAG="+tag,
+		"MICROSTATE_HASH="+hash,
+		"MICROSTATE_KIND="+kind(e),
+		"MICROSTATE_OPERATION="+Operation(e.Type),
+		"MICROSTATE_EVENT="+string(e.Type),
+		"MICROSTATE_PHASE="+string(h.When),
+	)
+	if err := cmd.Run(); err != nil {
+		return eris.Wrapf(ErrHookFailed, "hook: %s hook %q of %s: %v", h.When, h.Run, describe(e), err)
+	}
+	return nil
+}
+
+func orStderr(w io.Writer) io.Writer {
+	if w == nil {
+		return os.Stderr
+	}
+	return w
+}
@@ -0,0 +1,151 @@
+package hook_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	"github.com/hsblhsn/microstate/hook"
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+)
+
+func newEvents(g *goblin.G) []state.Event {
+	s := state.NewState()
+	s.Clock = state.FixedClock(time.Date(2021, 12, 20, 0, 0, 0, 0, time.UTC))
+	r, err := state.NewRelease(state.ReleaseKindDev, "v1.0.0-dev", state.VersionMap{"user-service": {Version: "3db20cf"}})
+	g.Assert(err).IsNil()
+	g.Assert(s.CreateRelease(r)).IsNil()
+	g.Assert(s.Promote(state.ReleaseKindDev)).IsNil()
+	s.Rollback()
+	return s.Events()
+}
+
+func TestRunner(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("Runner", func() {
+		g.It("should run the matching hooks with the release data", func() {
+			out := new(bytes.Buffer)
+			r := &hook.Runner{
+				Hooks: []hook.Hook{
+					{When: hook.Post, Operation: hook.OperationPublish, Kind: "alpha", Run: `echo "$MICROSTATE_PHASE $MICROSTATE_OPERATION $MICROSTATE_KIND $MICROSTATE_TAG"; grep -o '"tag":"v1.0.0-alpha"'`},
+					{When: hook.Post, Operation: hook.OperationRollback, Run: `echo "$MICROSTATE_EVENT $MICROSTATE_HASH"`},
+					{When: hook.Pre, Run: "echo pre"},
+				},
+				Stdout: out,
+			}
+			events := newEvents(g)
+			g.Assert(r.Run(hook.Post, events)).IsNil()
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			g.Assert(lines).Equal([]string{
+				"post publish alpha v1.0.0-alpha",
+				`"tag":"v1.0.0-alpha"`,
+				"release.rolled_back " + events[2].Release.BlockHash.String(),
+			})
+		})
+		g.It("should stop at the first failing pre hook", func() {
+			out := new(bytes.Buffer)
+			r := &hook.Runner{
+				Hooks: []hook.Hook{
+					{When: hook.Pre, Kind: "dev", Run: "exit 1"},
+					{When: hook.Pre, Run: "echo $MICROSTATE_TAG"},
+				},
+				Stdout: out,
+				Stderr: out,
+			}
+			err := r.Run(hook.Pre, newEvents(g))
+			g.Assert(eris.Cause(err)).Equal(hook.ErrHookFailed)
+			g.Assert(out.String()).Equal("")
+		})
+		g.It("should not match kinds of events without a valid release", func() {
+			out := new(bytes.Buffer)
+			r := &hook.Runner{
+				Hooks: []hook.Hook{
+					{When: hook.Post, Kind: "dev", Run: "echo dev"},
+					{When: hook.Post, Run: `echo "$MICROSTATE_EVENT [$MICROSTATE_KIND] [$MICROSTATE_TAG]"`},
+				},
+				Stdout: out,
+			}
+			events := []state.Event{
+				{Type: state.EventChainPruned},
+				{Type: state.EventChainPruned, Release: &state.Release{}},
+			}
+			g.Assert(r.Run(hook.Post, events)).IsNil()
+			g.Assert(out.String()).Equal("chain.pruned [] []\nchain.pruned [] []\n")
+			once := &hook.Once{Runner: r}
+			g.Assert(once.Run(hook.Post, events)).IsNil()
+		})
+		g.It("should skip kind filtered hooks when a prune empties the ledger", func() {
+			s := state.NewState()
+			r, err := state.NewRelease(state.ReleaseKindDev, "v1.0.0-dev", state.VersionMap{"user-service": {Version: "3db20cf"}})
+			g.Assert(err).IsNil()
+			g.Assert(s.CreateRelease(r)).IsNil()
+			b, err := s.Marshal()
+			g.Assert(err).IsNil()
+			s = state.NewState()
+			g.Assert(s.Load(b)).IsNil()
+			_, err = s.Prune(func(int, *state.Release) bool { return true }, "test")
+			g.Assert(err).IsNil()
+
+			out := new(bytes.Buffer)
+			runner := &hook.Runner{
+				Hooks: []hook.Hook{
+					{When: hook.Post, Kind: "dev", Run: "echo dev"},
+					{When: hook.Post, Operation: hook.OperationPrune, Run: `echo "$MICROSTATE_OPERATION"; grep -c '"checkpoint"'`},
+				},
+				Stdout: out,
+			}
+			g.Assert(runner.Run(hook.Post, s.Events())).IsNil()
+			g.Assert(out.String()).Equal("prune\n1\n")
+		})
+		g.It("should validate hooks", func() {
+			g.Assert(hook.Hook{When: hook.Pre, Kind: "rc", Run: "./smoke.sh"}.Validate()).IsNil()
+			g.Assert(eris.Cause(hook.Hook{When: "before", Run: "true"}.Validate())).Equal(hook.ErrHookInvalid)
+			g.Assert(eris.Cause(hook.Hook{When: hook.Pre, Kind: "prod", Run: "true"}.Validate())).Equal(hook.ErrHookInvalid)
+		})
+	})
+}
+
+func TestOnce(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("Once", func() {
+		publish := func(base []string, tag string) []state.Event {
+			s := state.NewState()
+			for _, v := range append(base, tag) {
+				r, err := state.NewRelease(state.ReleaseKindDev, v, state.VersionMap{"user-service": {Version: "3db20cf"}})
+				g.Assert(err).IsNil()
+				g.Assert(s.CreateRelease(r)).IsNil()
+			}
+			return s.Events()[len(base):]
+		}
+		g.It("should run the hooks of a replayed change once", func() {
+			out := new(bytes.Buffer)
+			once := &hook.Once{Runner: &hook.Runner{
+				Hooks:  []hook.Hook{{When: hook.Pre, Run: `echo "$MICROSTATE_TAG"`}},
+				Stdout: out,
+			}}
+			g.Assert(once.Run(hook.Pre, publish(nil, "v1.0.0-dev"))).IsNil()
+			// the branch moved, the same release is replayed on another block
+			g.Assert(once.Run(hook.Pre, publish([]string{"v0.9.0-dev"}, "v1.0.0-dev"))).IsNil()
+			g.Assert(out.String()).Equal("v1.0.0-dev\n")
+			// the replayed change got another tag
+			g.Assert(once.Run(hook.Pre, publish([]string{"v1.0.0-dev"}, "v1.0.1-dev"))).IsNil()
+			g.Assert(out.String()).Equal("v1.0.0-dev\nv1.0.1-dev\n")
+		})
+		g.It("should run the hooks again after a failure", func() {
+			runs := 0
+			once := &hook.Once{Runner: &hook.Runner{
+				Hooks:  []hook.Hook{{When: hook.Pre, Run: "exit 1"}},
+				Stderr: new(bytes.Buffer),
+			}}
+			for i := 0; i < 2; i++ {
+				if once.Run(hook.Pre, publish(nil, "v1.0.0-dev")) != nil {
+					runs++
+				}
+			}
+			g.Assert(runs).Equal(2)
+		})
+	})
+}
@@ -0,0 +1,218 @@
+// Package render renders the service versions of releases into deployment configuration files.
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/hsblhsn/microstate/source"
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrFormatInvalid = eris.New("render: export format is invalid")
+	ErrKeyCollision  = eris.New("render: export keys collide")
+)
+
+// Format is an export format.
+type Format string
+
+const (
+	FormatHelmValues      Format = "helm-values"
+	FormatKustomizeImages Format = "kustomize-images"
+	FormatDotenv          Format = "dotenv"
+	FormatTfvars          Format = "tfvars"
+	FormatJSON            Format = "json"
+)
+
+// Formats lists the supported export formats.
+var Formats = []Format{FormatHelmValues, FormatKustomizeImages, FormatDotenv, FormatTfvars, FormatJSON}
+
+// DefaultKeyTemplates are the key templates used when none is given.
+var DefaultKeyTemplates = map[Format]string{
+	FormatHelmValues:      "{{.Service}}.image.tag",
+	FormatKustomizeImages: "{{if .Repository}}{{.Repository}}{{else}}{{.Service}}{{end}}",
+	FormatDotenv:          "{{.Service | env}}_VERSION",
+	FormatTfvars:          "{{.Service | snake}}_version",
+	FormatJSON:            "{{.Service}}",
+}
+
+// Entry is the data passed to key templates for every service.
+type Entry struct {
+	Service string
+	state.ServiceVersion
+	// Repository, Tag and Digest are parsed from the image reference.
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// NewEntry returns the template data of a service.
+func NewEntry(svc string, v state.ServiceVersion) Entry {
+	e := Entry{
+		Service:        svc,
+		ServiceVersion: v,
+	}
+	if v.Image != "" {
+		e.Repository, e.Tag, e.Digest = source.ParseImage(v.Image)
+	}
+	return e
+}
+
+// Value returns the exported value of the service, the version falling back to the image tag.
+func (e Entry) Value() string {
+	if e.Version != "" {
+		return e.Version
+	}
+	if e.Tag != "" {
+		return e.Tag
+	}
+	return e.Primary()
+}
+
+var nonAlphaNumeric = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+
+// Funcs are the helper functions available to key and release templates.
+var Funcs = template.FuncMap{
+	"sortedServices": sortedServices,
+	"shortHash":      shortHash,
+	"semver":         semver.NewVersion,
+	"major":          func(v string) (uint64, error) { return semverPart(v, (*semver.Version).Major) },
+	"minor":          func(v string) (uint64, error) { return semverPart(v, (*semver.Version).Minor) },
+	"patch":          func(v string) (uint64, error) { return semverPart(v, (*semver.Version).Patch) },
+	"prerelease": func(v string) (string, error) {
+		parsed, err := semver.NewVersion(v)
+		if err != nil {
+			return "", err
+		}
+		return parsed.Prerelease(), nil
+	},
+	"env": func(s string) string {
+		return strings.ToUpper(nonAlphaNumeric.ReplaceAllString(s, "_"))
+	},
+	"snake": func(s string) string {
+		return strings.ToLower(nonAlphaNumeric.ReplaceAllString(s, "_"))
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// Export writes the service versions of the release in the given format.
+// An empty key template uses the default template of the format.
+func Export(w io.Writer, r *state.Release, format Format, keyTemplate string) error {
+	if keyTemplate == "" {
+		keyTemplate = DefaultKeyTemplates[format]
+	}
+	if keyTemplate == "" {
+		return eris.Wrapf(ErrFormatInvalid, "render: unknown format %q", format)
+	}
+	tpl, err := template.New("key").Funcs(Funcs).Parse(keyTemplate)
+	if err != nil {
+		return eris.Wrapf(err, "render: invalid key template %q", keyTemplate)
+	}
+	entries := make([]Entry, 0, len(r.Versions))
+	keys := make([]string, 0, len(r.Versions))
+	for _, svc := range r.Versions.Services() {
+		e := NewEntry(svc, r.Versions[svc])
+		var buf bytes.Buffer
+		if err := tpl.Execute(&buf, e); err != nil {
+			return eris.Wrapf(err, "render: could not render key of %s", svc)
+		}
+		entries = append(entries, e)
+		keys = append(keys, buf.String())
+	}
+	switch format {
+	case FormatHelmValues:
+		return exportHelmValues(w, entries, keys)
+	case FormatKustomizeImages:
+		return exportKustomizeImages(w, entries, keys)
+	case FormatDotenv:
+		for i, e := range entries {
+			fmt.Fprintf(w, "%s=%s\n", keys[i], strconv.Quote(e.Value()))
+		}
+		return nil
+	case FormatTfvars:
+		for i, e := range entries {
+			fmt.Fprintf(w, "%s = %s\n", keys[i], strconv.Quote(e.Value()))
+		}
+		return nil
+	case FormatJSON:
+		values := make(map[string]string, len(entries))
+		for i, e := range entries {
+			values[keys[i]] = e.Value()
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		return enc.Encode(values)
+	}
+	return eris.Wrapf(ErrFormatInvalid, "render: unknown format %q", format)
+}
+
+// exportHelmValues writes a values file. Dots in keys create nested maps.
+// It returns error if a key is used twice or is also the parent of another key.
+func exportHelmValues(w io.Writer, entries []Entry, keys []string) error {
+	values := make(map[string]interface{})
+	// owners holds the service that created every path, leaves and nested maps
+	owners := make(map[string]string)
+	for i, e := range entries {
+		path := strings.Split(keys[i], ".")
+		node := values
+		for j, p := range path[:len(path)-1] {
+			prefix := strings.Join(path[:j+1], ".")
+			existing, ok := node[p]
+			if !ok {
+				existing = make(map[string]interface{})
+				node[p] = existing
+				owners[prefix] = e.Service
+			}
+			next, ok := existing.(map[string]interface{})
+			if !ok {
+				return eris.Wrapf(ErrKeyCollision, "render: key %s of %s is nested in the value %s of %s", keys[i], e.Service, prefix, owners[prefix])
+			}
+			node = next
+		}
+		leaf := path[len(path)-1]
+		if _, ok := node[leaf]; ok {
+			return eris.Wrapf(ErrKeyCollision, "render: key %s of %s is already used by %s", keys[i], e.Service, owners[keys[i]])
+		}
+		node[leaf] = e.Value()
+		owners[keys[i]] = e.Service
+	}
+	return encodeYAML(w, values)
+}
+
+// exportKustomizeImages writes the images section of a kustomization file.
+func exportKustomizeImages(w io.Writer, entries []Entry, keys []string) error {
+	type image struct {
+		Name   string `yaml:"name"`
+		NewTag string `yaml:"newTag,omitempty"`
+		Digest string `yaml:"digest,omitempty"`
+	}
+	images := make([]image, 0, len(entries))
+	for i, e := range entries {
+		img := image{Name: keys[i], Digest: e.Digest}
+		if img.Digest == "" {
+			img.NewTag = e.Value()
+		}
+		images = append(images, img)
+	}
+	return encodeYAML(w, map[string]interface{}{"images": images})
+}
+
+func encodeYAML(w io.Writer, v interface{}) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,55 @@
+package render_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/franela/goblin"
+	"github.com/hsblhsn/microstate/render"
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+)
+
+func TestExport(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("Export", func() {
+		r := &state.Release{
+			Kind: state.ReleaseKindGA,
+			Tag:  "v1.0.0",
+			Versions: state.VersionMap{
+				"user-service":    {Version: "v1.2.0"},
+				"gateway-service": {Image: "ghcr.io/acme/gateway@sha256:abc"},
+			},
+		}
+		export := func(format render.Format, key string) string {
+			var buf bytes.Buffer
+			g.Assert(render.Export(&buf, r, format, key)).IsNil()
+			return buf.String()
+		}
+		g.It("should render helm values", func() {
+			g.Assert(export(render.FormatHelmValues, "")).Equal("gateway-service:\n  image:\n    tag: ghcr.io/acme/gateway@sha256:abc\nuser-service:\n  image:\n    tag: v1.2.0\n")
+		})
+		g.It("should render kustomize images", func() {
+			g.Assert(export(render.FormatKustomizeImages, "")).Equal("images:\n  - name: ghcr.io/acme/gateway\n    digest: sha256:abc\n  - name: user-service\n    newTag: v1.2.0\n")
+		})
+		g.It("should render dotenv with a custom key", func() {
+			g.Assert(export(render.FormatDotenv, "{{.Service | env}}_TAG")).Equal("GATEWAY_SERVICE_TAG=\"ghcr.io/acme/gateway@sha256:abc\"\nUSER_SERVICE_TAG=\"v1.2.0\"\n")
+		})
+		g.It("should render tfvars", func() {
+			g.Assert(export(render.FormatTfvars, "")).Equal("gateway_service_version = \"ghcr.io/acme/gateway@sha256:abc\"\nuser_service_version = \"v1.2.0\"\n")
+		})
+		g.It("should reject colliding helm values keys", func() {
+			var buf bytes.Buffer
+			err := render.Export(&buf, r, render.FormatHelmValues, "images")
+			g.Assert(eris.Cause(err)).Equal(render.ErrKeyCollision)
+			g.Assert(err.Error()).Equal("render: key images of user-service is already used by gateway-service: render: export keys collide")
+			err = render.Export(&buf, r, render.FormatHelmValues, `images{{if eq .Service "user-service"}}.user{{end}}`)
+			g.Assert(eris.Cause(err)).Equal(render.ErrKeyCollision)
+			g.Assert(err.Error()).Equal("render: key images.user of user-service is nested in the value images of gateway-service: render: export keys collide")
+		})
+		g.It("should reject an unknown format", func() {
+			err := render.Export(&bytes.Buffer{}, r, "xml", "")
+			g.Assert(eris.Cause(err)).Equal(render.ErrFormatInvalid)
+		})
+	})
+}
@@ -0,0 +1,69 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"text/template"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+)
+
+// Data is passed to release templates.
+type Data struct {
+	Release  *state.Release
+	Versions state.VersionMap
+	// Previous is the release of the same kind created before the release. It can be nil.
+	Previous *state.Release
+	// Changes are the service changes since the previous release.
+	Changes []state.VersionChange
+}
+
+// NewData returns the template data of the release.
+func NewData(r, previous *state.Release) Data {
+	d := Data{
+		Release:  r,
+		Versions: r.Versions,
+		Previous: previous,
+	}
+	var prev state.VersionMap
+	if previous != nil {
+		prev = previous.Versions
+	}
+	d.Changes = r.Versions.Diff(prev)
+	return d
+}
+
+// Template renders the release data with the given text/template source.
+func Template(w io.Writer, name, text string, data Data) error {
+	tpl, err := template.New(name).Funcs(Funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return eris.Wrapf(err, "render: could not parse template %s", name)
+	}
+	if err := tpl.Execute(w, data); err != nil {
+		return eris.Wrapf(err, "render: could not render template %s", name)
+	}
+	return nil
+}
+
+func sortedServices(m state.VersionMap) []string {
+	return m.Services()
+}
+
+// shortHash accepts a state.Hash or a string.
+func shortHash(v interface{}) string {
+	h := state.Hash(fmt.Sprint(v))
+	if short := h.Short(); short != "" {
+		return short
+	}
+	return h.String()
+}
+
+func semverPart(v string, part func(*semver.Version) uint64) (uint64, error) {
+	parsed, err := semver.NewVersion(v)
+	if err != nil {
+		return 0, err
+	}
+	return part(parsed), nil
+}
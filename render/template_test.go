@@ -0,0 +1,38 @@
+package render_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/franela/goblin"
+	"github.com/hsblhsn/microstate/render"
+	"github.com/hsblhsn/microstate/state"
+)
+
+func TestTemplate(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("Template", func() {
+		previous := &state.Release{
+			Kind:     state.ReleaseKindRC,
+			Tag:      "v1.1.0-rc",
+			Versions: state.VersionMap{"a": {Version: "1"}, "b": {Version: "1"}},
+		}
+		r := &state.Release{
+			Kind:      state.ReleaseKindRC,
+			Tag:       "v1.2.0-rc",
+			Versions:  state.VersionMap{"b": {Version: "2"}, "a": {Version: "1"}},
+			BlockHash: "36c6b27f0aa85aa5fd9ed5c1c913c7c2d39fda5f8427b421da9f0c18fea2c4ef",
+		}
+		g.It("should expose the release, the previous release and the changes", func() {
+			var buf bytes.Buffer
+			text := `{{.Release.Tag}} {{shortHash .Release.BlockHash}} {{minor .Release.Tag}} {{prerelease .Release.Tag}}` +
+				`{{range sortedServices .Versions}} {{.}}{{end}} since {{.Previous.Tag}}:` +
+				`{{range .Changes}} {{.Service}} {{.From}}->{{.To}}{{end}}`
+			g.Assert(render.Template(&buf, "test", text, render.NewData(r, previous))).IsNil()
+			g.Assert(buf.String()).Equal("v1.2.0-rc 36c6b27f0 2 rc a b since v1.1.0-rc: b 1->2")
+		})
+		g.It("should report template errors", func() {
+			g.Assert(render.Template(&bytes.Buffer{}, "test", "{{major .Release.Kind}}", render.NewData(r, nil))).IsNotNil()
+		})
+	})
+}
@@ -0,0 +1,234 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+)
+
+var (
+	ErrUnauthorized = eris.New("server: authentication required")
+	ErrForbidden    = eris.New("server: operation not allowed")
+	ErrTokenInvalid = eris.New("server: token is invalid")
+)
+
+// Operation is an action on the ledger that roles can be allowed to perform.
+// Roles list operations or patterns ending with *, e.g. promote-to-* or *.
+type Operation string
+
+const (
+	OpRead       Operation = "read"
+	OpPublishDev Operation = "publish-dev"
+)
+
+// OpPromoteTo returns the operation of promoting to the given kind. e.g. promote-to-ga
+func OpPromoteTo(kind state.ReleaseKind) Operation {
+	return Operation("promote-to-" + kind.String())
+}
+
+// OpRollback returns the operation of rolling back a head release of the given kind. e.g. rollback-dev
+func OpRollback(kind state.ReleaseKind) Operation {
+	return Operation("rollback-" + kind.String())
+}
+
+// Match reports whether the operation matches the given operation or pattern.
+func (op Operation) Match(pattern Operation) bool {
+	if strings.HasSuffix(string(pattern), "*") {
+		return strings.HasPrefix(string(op), strings.TrimSuffix(string(pattern), "*"))
+	}
+	return op == pattern
+}
+
+// Identity is an authenticated caller.
+type Identity struct {
+	Name  string
+	Roles []string
+}
+
+// Authenticator returns the identity of a bearer token.
+type Authenticator interface {
+	Authenticate(token string) (*Identity, error)
+}
+
+// StaticToken is a token declared in the auth file.
+type StaticToken struct {
+	Name  string   `json:"name"`
+	Token string   `json:"token"`
+	Roles []string `json:"roles"`
+}
+
+// StaticTokens authenticates the declared tokens.
+type StaticTokens []StaticToken
+
+func (t StaticTokens) Authenticate(token string) (*Identity, error) {
+	for _, v := range t {
+		if v.Token != "" && subtle.ConstantTimeCompare([]byte(v.Token), []byte(token)) == 1 {
+			return &Identity{Name: v.Name, Roles: v.Roles}, nil
+		}
+	}
+	return nil, ErrTokenInvalid
+}
+
+// Claims are the content of a signed token.
+type Claims struct {
+	Subject string   `json:"sub"`
+	Roles   []string `json:"roles"`
+	// ExpiresAt is a unix timestamp. Zero never expires.
+	ExpiresAt int64 `json:"exp,omitempty"`
+}
+
+// HMACTokens authenticates tokens signed with a shared secret.
+// A token is the base64 encoded claims and their HMAC-SHA256 signature joined by a dot.
+type HMACTokens struct {
+	Secret []byte
+	// Clock checks the expiry of tokens. Defaults to the system clock.
+	Clock state.Clock
+}
+
+// Sign returns a token of the claims.
+func (h *HMACTokens) Sign(c Claims) (string, error) {
+	if len(h.Secret) == 0 {
+		return "", eris.New("server: hmac secret is empty")
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(h.mac(payload)), nil
+}
+
+func (h *HMACTokens) Authenticate(token string) (*Identity, error) {
+	payload, signature, ok := cut(token, ".")
+	if !ok || len(h.Secret) == 0 {
+		return nil, ErrTokenInvalid
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, h.mac(payload)) {
+		return nil, ErrTokenInvalid
+	}
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrTokenInvalid
+	}
+	c := new(Claims)
+	if err := json.Unmarshal(b, c); err != nil || c.Subject == "" {
+		return nil, ErrTokenInvalid
+	}
+	now := time.Now()
+	if h.Clock != nil {
+		now = h.Clock.Now()
+	}
+	if c.ExpiresAt != 0 && now.Unix() >= c.ExpiresAt {
+		return nil, eris.Wrapf(ErrTokenInvalid, "server: token of %s expired", c.Subject)
+	}
+	return &Identity{Name: c.Subject, Roles: c.Roles}, nil
+}
+
+func (h *HMACTokens) mac(payload string) []byte {
+	m := hmac.New(sha256.New, h.Secret)
+	m.Write([]byte(payload))
+	return m.Sum(nil)
+}
+
+// Auth authenticates requests and authorizes their operations.
+type Auth struct {
+	Authenticators []Authenticator
+	// Roles map role names to the operations they allow.
+	Roles map[string][]Operation
+}
+
+// Allows reports whether any role of the identity allows the operation.
+func (a *Auth) Allows(id *Identity, op Operation) bool {
+	for _, role := range id.Roles {
+		for _, pattern := range a.Roles[role] {
+			if op.Match(pattern) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Authorize returns the identity of the bearer token of the request
+// if it is allowed to perform the operation.
+func (a *Auth) Authorize(r *http.Request, op Operation) (*Identity, error) {
+	id, err := a.Authenticate(r)
+	if err != nil {
+		return nil, err
+	}
+	if !a.Allows(id, op) {
+		return nil, eris.Wrapf(ErrForbidden, "server: %s is not allowed to %s", id.Name, op)
+	}
+	return id, nil
+}
+
+// Authenticate returns the identity of the bearer token of the request.
+func (a *Auth) Authenticate(r *http.Request) (*Identity, error) {
+	token := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	if token == "" {
+		return nil, ErrUnauthorized
+	}
+	var id *Identity
+	for _, authenticator := range a.Authenticators {
+		if v, err := authenticator.Authenticate(token); err == nil {
+			id = v
+			break
+		}
+	}
+	if id == nil {
+		return nil, eris.Wrap(ErrUnauthorized, ErrTokenInvalid.Error())
+	}
+	return id, nil
+}
+
+// AuthConfig is the format of the auth file.
+type AuthConfig struct {
+	Tokens StaticTokens `json:"tokens,omitempty"`
+	// HMACSecretEnv names the environment variable holding the secret of signed tokens.
+	// Signed tokens are rejected if it is not set.
+	HMACSecretEnv string `json:"hmac_secret_env,omitempty"`
+	// Roles map role names to the operations they allow.
+	Roles map[string][]Operation `json:"roles"`
+}
+
+// LoadAuth reads the auth file.
+func LoadAuth(path string) (*Auth, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := new(AuthConfig)
+	if err := json.Unmarshal(b, cfg); err != nil {
+		return nil, eris.Wrap(err, "server: could not parse auth file")
+	}
+	a := &Auth{
+		Authenticators: []Authenticator{cfg.Tokens},
+		Roles:          cfg.Roles,
+	}
+	if cfg.HMACSecretEnv != "" {
+		secret := os.Getenv(cfg.HMACSecretEnv)
+		if secret == "" {
+			return nil, eris.Errorf("server: %s is not set", cfg.HMACSecretEnv)
+		}
+		a.Authenticators = append(a.Authenticators, &HMACTokens{Secret: []byte(secret)})
+	}
+	return a, nil
+}
+
+// cut slices s around the first instance of sep.
+func cut(s, sep string) (string, string, bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
@@ -0,0 +1,117 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	"github.com/hsblhsn/microstate/server"
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+)
+
+func TestHMACTokens(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("HMACTokens", func() {
+		h := &server.HMACTokens{
+			Secret: []byte("secret"),
+			Clock:  state.FixedClock(time.Unix(1000, 0)),
+		}
+		g.It("should authenticate signed tokens", func() {
+			token, err := h.Sign(server.Claims{Subject: "deploy-bot", Roles: []string{"dev"}, ExpiresAt: 2000})
+			g.Assert(err).IsNil()
+			id, err := h.Authenticate(token)
+			g.Assert(err).IsNil()
+			g.Assert(*id).Equal(server.Identity{Name: "deploy-bot", Roles: []string{"dev"}})
+		})
+		g.It("should reject tampered and expired tokens", func() {
+			other := &server.HMACTokens{Secret: []byte("other")}
+			token, err := other.Sign(server.Claims{Subject: "deploy-bot", Roles: []string{"admin"}})
+			g.Assert(err).IsNil()
+			_, err = h.Authenticate(token)
+			g.Assert(eris.Cause(err)).Equal(server.ErrTokenInvalid)
+			token, err = h.Sign(server.Claims{Subject: "deploy-bot", ExpiresAt: 1000})
+			g.Assert(err).IsNil()
+			_, err = h.Authenticate(token)
+			g.Assert(eris.Cause(err)).Equal(server.ErrTokenInvalid)
+		})
+	})
+}
+
+func TestAuth(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("Auth", func() {
+		var ts *httptest.Server
+		g.Before(func() {
+			backend, err := server.NewMemoryBackend(nil)
+			g.Assert(err).IsNil()
+			srv := server.New(backend)
+			srv.Auth = &server.Auth{
+				Authenticators: []server.Authenticator{server.StaticTokens{
+					{Name: "reader", Token: "read-token", Roles: []string{"reader"}},
+					{Name: "ci", Token: "ci-token", Roles: []string{"developer"}},
+					{Name: "alice", Token: "alice-token", Roles: []string{"release-manager"}},
+				}},
+				Roles: map[string][]server.Operation{
+					"reader":          {server.OpRead},
+					"developer":       {server.OpRead, server.OpPublishDev, "rollback-dev"},
+					"release-manager": {server.OpRead, "promote-to-*"},
+				},
+			}
+			ts = httptest.NewServer(srv)
+		})
+		g.After(func() {
+			ts.Close()
+		})
+		create := server.CreateRequest{Versions: state.VersionMap{"user-service": {Version: "v1.0.0"}}}
+		g.It("should require a token", func() {
+			e := new(server.Error)
+			res := do(g, http.MethodGet, ts.URL+"/releases", nil, nil, e)
+			g.Assert(res.StatusCode).Equal(http.StatusUnauthorized)
+			g.Assert(e.Code).Equal("unauthorized")
+			res = do(g, http.MethodGet, ts.URL+"/releases", http.Header{"Authorization": {"Bearer wrong"}}, nil, e)
+			g.Assert(res.StatusCode).Equal(http.StatusUnauthorized)
+		})
+		g.It("should authorize operations by role", func() {
+			e := new(server.Error)
+			res := do(g, http.MethodPost, ts.URL+"/releases", http.Header{"Authorization": {"Bearer read-token"}}, create, e)
+			g.Assert(res.StatusCode).Equal(http.StatusForbidden)
+			g.Assert(e.Code).Equal("forbidden")
+
+			r := new(state.Release)
+			res = do(g, http.MethodPost, ts.URL+"/releases", http.Header{"Authorization": {"Bearer ci-token"}}, create, r)
+			g.Assert(res.StatusCode).Equal(http.StatusCreated)
+			g.Assert(r.Origin.Actor).Equal("ci")
+
+			res = do(g, http.MethodPost, ts.URL+"/promote/alpha", http.Header{"Authorization": {"Bearer ci-token"}}, nil, e)
+			g.Assert(res.StatusCode).Equal(http.StatusForbidden)
+			res = do(g, http.MethodPost, ts.URL+"/promote/alpha", http.Header{"Authorization": {"Bearer alice-token"}}, nil, r)
+			g.Assert(res.StatusCode).Equal(http.StatusCreated)
+			g.Assert(r.Origin.Actor).Equal("alice")
+
+			res = do(g, http.MethodPost, ts.URL+"/rollback", http.Header{"Authorization": {"Bearer alice-token"}}, nil, e)
+			g.Assert(res.StatusCode).Equal(http.StatusForbidden)
+		})
+		g.It("should authorize rollbacks by the kind of the head release", func() {
+			e := new(server.Error)
+			res := do(g, http.MethodPost, ts.URL+"/rollback", http.Header{"Authorization": {"Bearer ci-token"}}, nil, e)
+			g.Assert(res.StatusCode).Equal(http.StatusForbidden)
+			g.Assert(strings.Contains(e.Message, "rollback-alpha")).IsTrue()
+
+			r := new(state.Release)
+			res = do(g, http.MethodPost, ts.URL+"/releases", http.Header{"Authorization": {"Bearer ci-token"}}, create, r)
+			g.Assert(res.StatusCode).Equal(http.StatusCreated)
+			res = do(g, http.MethodPost, ts.URL+"/rollback", http.Header{"Authorization": {"Bearer ci-token"}}, nil, r)
+			g.Assert(res.StatusCode).Equal(http.StatusOK)
+			g.Assert(r.Kind).Equal(state.ReleaseKindDev)
+		})
+		g.It("should reject an invalid kind before authorizing", func() {
+			e := new(server.Error)
+			res := do(g, http.MethodPost, ts.URL+"/promote/all", http.Header{"Authorization": {"Bearer alice-token"}}, nil, e)
+			g.Assert(res.StatusCode).Equal(http.StatusBadRequest)
+		})
+	})
+}
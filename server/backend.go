@@ -0,0 +1,83 @@
+package server
+
+import (
+	"os"
+	"sync"
+
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+)
+
+// Backend loads and stores the state served by the server.
+// The server serializes the calls, a backend does not need to be safe for concurrent use.
+type Backend interface {
+	Load() (*state.State, error)
+	Save(s *state.State) error
+}
+
+// FileBackend keeps the state in a state file.
+// The file is read on every request, so changes made by the cli are served immediately.
+// A missing file is served as an empty state and created by the first write.
+type FileBackend struct {
+	Path string
+}
+
+// NewFileBackend returns a backend of the given state file.
+func NewFileBackend(path string) *FileBackend {
+	return &FileBackend{Path: path}
+}
+
+func (b *FileBackend) Load() (*state.State, error) {
+	s := state.NewState()
+	if err := s.Import(b.Path); os.IsNotExist(eris.Cause(err)) {
+		return state.NewState(), nil
+	} else if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (b *FileBackend) Save(s *state.State) error {
+	return s.Export(b.Path)
+}
+
+// MemoryBackend keeps the state in memory.
+// It stores the encoded state, so the loaded states are safe to modify.
+type MemoryBackend struct {
+	mu sync.Mutex
+	b  []byte
+}
+
+// NewMemoryBackend returns a backend holding the given state.
+// A nil state starts with an empty state.
+func NewMemoryBackend(s *state.State) (*MemoryBackend, error) {
+	if s == nil {
+		s = state.NewState()
+	}
+	backend := new(MemoryBackend)
+	if err := backend.Save(s); err != nil {
+		return nil, err
+	}
+	return backend, nil
+}
+
+func (b *MemoryBackend) Load() (*state.State, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := state.NewState()
+	if err := s.Load(b.b); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (b *MemoryBackend) Save(s *state.State) error {
+	encoded, err := s.Marshal()
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.b = encoded
+	return nil
+}
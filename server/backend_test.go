@@ -0,0 +1,38 @@
+package server_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/franela/goblin"
+	"github.com/hsblhsn/microstate/server"
+	"github.com/hsblhsn/microstate/state"
+)
+
+func TestFileBackend(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("FileBackend", func() {
+		g.It("should load a missing state file as an empty state", func() {
+			path := filepath.Join(t.TempDir(), ".state.json")
+			backend := server.NewFileBackend(path)
+			s, err := backend.Load()
+			g.Assert(err).IsNil()
+			g.Assert(len(s.Releases)).Equal(0)
+
+			r, err := state.NewRelease(state.ReleaseKindDev, "v0.0.1-dev", state.VersionMap{"user-service": {Version: "3db20cf"}})
+			g.Assert(err).IsNil()
+			g.Assert(s.CreateRelease(r)).IsNil()
+			g.Assert(backend.Save(s)).IsNil()
+			s, err = backend.Load()
+			g.Assert(err).IsNil()
+			g.Assert(len(s.Releases)).Equal(1)
+		})
+		g.It("should fail on an unreadable state file", func() {
+			path := filepath.Join(t.TempDir(), ".state.json")
+			g.Assert(os.WriteFile(path, []byte("{"), 0644)).IsNil()
+			_, err := server.NewFileBackend(path).Load()
+			g.Assert(err).IsNotNil()
+		})
+	})
+}
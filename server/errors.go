@@ -0,0 +1,65 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/hsblhsn/microstate/hook"
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+)
+
+var (
+	ErrNotFound           = eris.New("server: not found")
+	ErrBadRequest         = eris.New("server: bad request")
+	ErrPreconditionFailed = eris.New("server: the head release does not match If-Match")
+	ErrMethodNotAllowed   = eris.New("server: method not allowed")
+)
+
+// Error is the body of error responses.
+type Error struct {
+	// Code identifies the sentinel error, see Codes.
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ErrorCode describes how a sentinel error is reported.
+type ErrorCode struct {
+	Code   string
+	Status int
+	Err    error
+}
+
+// Codes lists the sentinel errors the server reports with their own code.
+// Other errors are reported as internal errors.
+var Codes = []ErrorCode{
+	{"no_release", http.StatusNotFound, state.ErrNoRelease},
+	{"release_not_found", http.StatusNotFound, state.ErrReleaseNotFound},
+	{"release_tag_invalid", http.StatusUnprocessableEntity, state.ErrReleaseTagInvalid},
+	{"release_kind_invalid", http.StatusUnprocessableEntity, state.ErrReleaseKindInvalid},
+	{"release_kind_not_dev", http.StatusUnprocessableEntity, state.ErrReleaseKindIsNotDev},
+	{"service_map_invalid", http.StatusUnprocessableEntity, state.ErrServiceMapInvalid},
+	{"annotation_invalid", http.StatusUnprocessableEntity, state.ErrAnnotationInvalid},
+	{"service_version_invalid", http.StatusUnprocessableEntity, state.ErrServiceVersionInvalid},
+	{"version_format_invalid", http.StatusUnprocessableEntity, state.ErrVersionFormatInvalid},
+	{"service_unknown", http.StatusUnprocessableEntity, state.ErrServiceUnknown},
+	{"constraint_violated", http.StatusUnprocessableEntity, state.ErrConstraintViolated},
+	{"bump_invalid", http.StatusBadRequest, state.ErrBumpInvalid},
+	{"hook_failed", http.StatusConflict, hook.ErrHookFailed},
+	{"unauthorized", http.StatusUnauthorized, ErrUnauthorized},
+	{"forbidden", http.StatusForbidden, ErrForbidden},
+	{"not_found", http.StatusNotFound, ErrNotFound},
+	{"bad_request", http.StatusBadRequest, ErrBadRequest},
+	{"precondition_failed", http.StatusPreconditionFailed, ErrPreconditionFailed},
+	{"method_not_allowed", http.StatusMethodNotAllowed, ErrMethodNotAllowed},
+}
+
+// lookup returns the code of the error.
+func lookup(err error) ErrorCode {
+	for _, c := range Codes {
+		if eris.Is(err, c.Err) || errors.Is(err, c.Err) {
+			return c
+		}
+	}
+	return ErrorCode{Code: "internal", Status: http.StatusInternalServerError}
+}
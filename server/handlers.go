@@ -0,0 +1,172 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+)
+
+func list(s *state.State, r *http.Request) (interface{}, error) {
+	query := r.URL.Query()
+	var kind state.ReleaseKind
+	if v := query.Get("kind"); v != "" {
+		k, err := state.NewReleaseKindFromString(v)
+		if err != nil {
+			return nil, eris.Wrapf(ErrBadRequest, "server: invalid kind %q", v)
+		}
+		kind = k
+	}
+	filter := make(state.Annotations)
+	for _, v := range query["annotation"] {
+		key, value, err := state.ParseAnnotation(v)
+		if err != nil {
+			return nil, eris.Wrapf(ErrBadRequest, "server: invalid annotation %q", v)
+		}
+		filter[key] = value
+	}
+	limit := 0
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, eris.Wrapf(ErrBadRequest, "server: invalid limit %q", v)
+		}
+		limit = n
+	}
+	service := query.Get("service")
+	releases := make([]*state.Release, 0)
+	for _, v := range s.Releases {
+		if limit > 0 && len(releases) >= limit {
+			break
+		}
+		if kind != 0 && !v.Kind.Is(kind) {
+			continue
+		}
+		if !v.Annotations.Match(filter) {
+			continue
+		}
+		if service != "" {
+			if _, err := s.Registry.Rules().Get(v.Versions, service); err != nil {
+				continue
+			}
+		}
+		releases = append(releases, v)
+	}
+	return releases, nil
+}
+
+func create(s *state.State, r *http.Request) (interface{}, error) {
+	req := new(CreateRequest)
+	if err := decode(r, req); err != nil {
+		return nil, err
+	}
+	versions := state.NewVersionMap()
+	if req.From != "" {
+		from, err := s.Resolve(req.From)
+		if err != nil {
+			return nil, err
+		}
+		versions = from.Versions
+	}
+	for svc, v := range req.Versions {
+		s.Registry.Rules().Set(versions, svc, v)
+	}
+	if unknown := s.Registry.Unknown(versions); len(unknown) != 0 {
+		return nil, eris.Wrapf(state.ErrServiceUnknown, "server: unknown services %s", strings.Join(unknown, ", "))
+	}
+	tag := req.Tag
+	if tag == "" {
+		next, err := s.NextDevTag(req.Bump)
+		if err != nil {
+			return nil, err
+		}
+		tag = next
+	}
+	release, err := state.NewRelease(state.ReleaseKindDev, tag, versions)
+	if err != nil {
+		return nil, err
+	}
+	if err := release.Apply(state.WithAnnotations(req.Annotations), state.WithNotes(req.Notes)); err != nil {
+		return nil, err
+	}
+	if err := s.CreateRelease(release); err != nil {
+		return nil, err
+	}
+	return s.Head()
+}
+
+func diff(s *state.State, ref, from string) (interface{}, error) {
+	to, err := s.Resolve(ref)
+	if err != nil {
+		return nil, err
+	}
+	var prev *state.Release
+	if from != "" {
+		prev, err = s.Resolve(from)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		prev = s.Previous(to)
+	}
+	var prevVersions state.VersionMap
+	if prev != nil {
+		prevVersions = prev.Versions
+	}
+	return &Diff{
+		From:    prev,
+		To:      to,
+		Changes: to.Versions.Diff(prevVersions),
+	}, nil
+}
+
+func promote(s *state.State, to state.ReleaseKind, r *http.Request) (interface{}, error) {
+	req := new(PromoteRequest)
+	if err := decode(r, req); err != nil {
+		return nil, err
+	}
+	if err := s.PromoteTo(to, state.WithAnnotations(req.Annotations), state.WithNotes(req.Notes)); err != nil {
+		return nil, err
+	}
+	return s.Head()
+}
+
+func rollback(s *state.State, r *http.Request) (interface{}, error) {
+	head, err := s.Head()
+	if err != nil {
+		return nil, err
+	}
+	s.Rollback()
+	return head, nil
+}
+
+func verify(s *state.State, r *http.Request) (interface{}, error) {
+	fromCheckpoint := r.URL.Query().Get("from_checkpoint") == "true"
+	validate := s.Validate
+	if fromCheckpoint {
+		validate = s.ValidateFromCheckpoint
+	}
+	v := &Verification{OK: true, Full: !fromCheckpoint}
+	if err := validate(); err != nil {
+		v.OK = false
+		v.Error = err.Error()
+	}
+	return v, nil
+}
+
+// decode decodes the json body of the request. An empty body is accepted.
+func decode(r *http.Request, v interface{}) error {
+	if r.Body == nil {
+		return nil
+	}
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil && err != io.EOF {
+		return eris.Wrapf(ErrBadRequest, "server: invalid request body: %v", err)
+	}
+	return nil
+}
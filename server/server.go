@@ -0,0 +1,285 @@
+// Package server exposes the release ledger over a REST API.
+//
+//	GET  /head                      head release
+//	GET  /releases                  releases, newest first. ?kind=dev&service=x&annotation=k=v&limit=10
+//	POST /releases                  create a dev release, see CreateRequest
+//	GET  /releases/{ref}            release by kind, tag or block hash prefix
+//	GET  /releases/{ref}/diff       service version changes. ?from=ref, defaults to the previous release of the same kind
+//	POST /promote/{kind}            promote the latest release of the previous kind, see PromoteRequest
+//	POST /rollback                  remove the head release
+//	GET  /verify                    verify the ledger. ?from_checkpoint=true only re-hashes the releases down to the latest checkpoint
+//
+// Requests are authorized per operation if the server has auth, see Auth and Operation.
+// Every response carries the block hash of the head release as ETag.
+// Writes with an If-Match header fail with 412 if the head release changed.
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+)
+
+// emptyETag is the ETag of a state without releases.
+const emptyETag = `"empty"`
+
+// CreateRequest is the body of a dev release creation.
+type CreateRequest struct {
+	Versions    state.VersionMap  `json:"versions"`
+	Annotations state.Annotations `json:"annotations,omitempty"`
+	Notes       string            `json:"notes,omitempty"`
+	// Tag of the release. Defaults to the next dev tag of the bump.
+	Tag  string     `json:"tag,omitempty"`
+	Bump state.Bump `json:"bump,omitempty"`
+	// From references a release whose versions are copied before applying Versions.
+	From string `json:"from,omitempty"`
+}
+
+// PromoteRequest is the body of a promotion.
+type PromoteRequest struct {
+	Annotations state.Annotations `json:"annotations,omitempty"`
+	Notes       string            `json:"notes,omitempty"`
+}
+
+// Diff is the response of the diff endpoint.
+type Diff struct {
+	From    *state.Release        `json:"from,omitempty"`
+	To      *state.Release        `json:"to"`
+	Changes []state.VersionChange `json:"changes"`
+}
+
+// Verification is the response of the verify endpoint.
+type Verification struct {
+	OK bool `json:"ok"`
+	// Full is false if only the releases down to the latest checkpoint were re-hashed.
+	Full  bool   `json:"full"`
+	Error string `json:"error,omitempty"`
+}
+
+// Server serves the state of a backend.
+type Server struct {
+	Backend Backend
+	// Prepare configures the state before a write, e.g. its clock, origin and registry.
+	Prepare func(s *state.State, r *http.Request) error
+	// Auth authenticates and authorizes requests. Nil allows every request.
+	Auth *Auth
+	// BeforeSave is called with every changed state before it is saved.
+	// An error aborts the change.
+	BeforeSave func(s *state.State) error
+	// OnEvents is called with the events of every saved change.
+	OnEvents func(events []state.Event)
+
+	mu sync.Mutex
+}
+
+// New returns a server of the given backend.
+func New(backend Backend) *Server {
+	return &Server{Backend: backend}
+}
+
+func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "head":
+		srv.read(w, r, OpRead, func(s *state.State) (interface{}, error) {
+			return s.Head()
+		})
+	case len(parts) == 1 && parts[0] == "releases" && r.Method == http.MethodPost:
+		srv.write(w, r, allow(OpPublishDev), http.StatusCreated, create)
+	case len(parts) == 1 && parts[0] == "releases":
+		srv.read(w, r, OpRead, func(s *state.State) (interface{}, error) {
+			return list(s, r)
+		})
+	case len(parts) == 2 && parts[0] == "releases":
+		srv.read(w, r, OpRead, func(s *state.State) (interface{}, error) {
+			return s.Resolve(parts[1])
+		})
+	case len(parts) == 3 && parts[0] == "releases" && parts[2] == "diff":
+		srv.read(w, r, OpRead, func(s *state.State) (interface{}, error) {
+			return diff(s, parts[1], r.URL.Query().Get("from"))
+		})
+	case len(parts) == 2 && parts[0] == "promote":
+		kind, err := state.NewReleaseKindFromString(parts[1])
+		if err != nil {
+			writeError(w, eris.Wrapf(ErrBadRequest, "server: invalid kind %q", parts[1]))
+			return
+		}
+		srv.write(w, r, allow(OpPromoteTo(kind)), http.StatusCreated, func(s *state.State, r *http.Request) (interface{}, error) {
+			return promote(s, kind, r)
+		})
+	case len(parts) == 1 && parts[0] == "rollback":
+		// a rollback is authorized by the kind of the head release it removes
+		srv.write(w, r, func(s *state.State) (Operation, error) {
+			head, err := s.Head()
+			if err != nil {
+				return "", err
+			}
+			return OpRollback(head.Kind), nil
+		}, http.StatusOK, rollback)
+	case len(parts) == 1 && parts[0] == "verify":
+		srv.read(w, r, OpRead, func(s *state.State) (interface{}, error) {
+			return verify(s, r)
+		})
+	default:
+		writeError(w, eris.Wrapf(ErrNotFound, "server: %s %s", r.Method, r.URL.Path))
+	}
+}
+
+// read serves a read-only request.
+func (srv *Server) read(w http.ResponseWriter, r *http.Request, op Operation, fn func(s *state.State) (interface{}, error)) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, eris.Wrapf(ErrMethodNotAllowed, "server: %s %s", r.Method, r.URL.Path))
+		return
+	}
+	if _, err := srv.authorize(r, op); err != nil {
+		writeError(w, err)
+		return
+	}
+	srv.mu.Lock()
+	s, err := srv.Backend.Load()
+	srv.mu.Unlock()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	tag := ETag(s)
+	w.Header().Set("ETag", tag)
+	if match := r.Header.Get("If-None-Match"); match != "" && matchETag(match, tag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	v, err := fn(s)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+// operationFunc returns the operation a write on the current state performs.
+type operationFunc func(s *state.State) (Operation, error)
+
+// allow returns the operationFunc of an operation that does not depend on the state.
+func allow(op Operation) operationFunc {
+	return func(*state.State) (Operation, error) {
+		return op, nil
+	}
+}
+
+// write serves a request changing the state.
+// The state is saved only if the function succeeds.
+// The authenticated identity is recorded as the actor of created releases.
+func (srv *Server) write(w http.ResponseWriter, r *http.Request, opFn operationFunc, status int, fn func(s *state.State, r *http.Request) (interface{}, error)) {
+	if r.Method != http.MethodPost {
+		writeError(w, eris.Wrapf(ErrMethodNotAllowed, "server: %s %s", r.Method, r.URL.Path))
+		return
+	}
+	var id *Identity
+	if srv.Auth != nil {
+		v, err := srv.Auth.Authenticate(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		id = v
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	s, err := srv.Backend.Load()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	op, err := opFn(s)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if id != nil && !srv.Auth.Allows(id, op) {
+		writeError(w, eris.Wrapf(ErrForbidden, "server: %s is not allowed to %s", id.Name, op))
+		return
+	}
+	if match := r.Header.Get("If-Match"); match != "" && !matchETag(match, ETag(s)) {
+		w.Header().Set("ETag", ETag(s))
+		writeError(w, eris.Wrapf(ErrPreconditionFailed, "server: head is %s", ETag(s)))
+		return
+	}
+	if srv.Prepare != nil {
+		if err := srv.Prepare(s, r); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	if id != nil {
+		s.Origin.Actor = id.Name
+	}
+	v, err := fn(s, r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if srv.BeforeSave != nil {
+		if err := srv.BeforeSave(s); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	if err := srv.Backend.Save(s); err != nil {
+		writeError(w, err)
+		return
+	}
+	if srv.OnEvents != nil {
+		srv.OnEvents(s.Events())
+	}
+	w.Header().Set("ETag", ETag(s))
+	writeJSON(w, status, v)
+}
+
+// authorize returns the identity of the request if it may perform the operation.
+// It returns nil if the server has no auth.
+func (srv *Server) authorize(r *http.Request, op Operation) (*Identity, error) {
+	if srv.Auth == nil {
+		return nil, nil
+	}
+	return srv.Auth.Authorize(r, op)
+}
+
+// ETag returns the entity tag of the state, the quoted block hash of its head release.
+func ETag(s *state.State) string {
+	if len(s.Releases) == 0 {
+		return emptyETag
+	}
+	return strconv.Quote(s.Releases[0].BlockHash.String())
+}
+
+// matchETag reports whether the If-Match or If-None-Match header value matches the tag.
+func matchETag(header, tag string) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+		if v == "*" || v == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	_ = enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	c := lookup(err)
+	if c.Status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="microstate"`)
+	}
+	writeJSON(w, c.Status, Error{Code: c.Code, Message: err.Error()})
+}
@@ -0,0 +1,175 @@
+package server_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	"github.com/hsblhsn/microstate/hook"
+	"github.com/hsblhsn/microstate/server"
+	"github.com/hsblhsn/microstate/state"
+)
+
+func newTestServer(g *goblin.G) *httptest.Server {
+	backend, err := server.NewMemoryBackend(nil)
+	g.Assert(err).IsNil()
+	srv := server.New(backend)
+	srv.Prepare = func(s *state.State, r *http.Request) error {
+		s.Clock = state.FixedClock(time.Date(2021, 12, 20, 0, 0, 0, 0, time.UTC))
+		return nil
+	}
+	return httptest.NewServer(srv)
+}
+
+// do sends the request and decodes the response body into v.
+func do(g *goblin.G, method, url string, header http.Header, body interface{}, v interface{}) *http.Response {
+	var b []byte
+	if body != nil {
+		var err error
+		b, err = json.Marshal(body)
+		g.Assert(err).IsNil()
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(b))
+	g.Assert(err).IsNil()
+	for k := range header {
+		req.Header.Set(k, header.Get(k))
+	}
+	res, err := http.DefaultClient.Do(req)
+	g.Assert(err).IsNil()
+	defer res.Body.Close()
+	if v != nil {
+		g.Assert(json.NewDecoder(res.Body).Decode(v)).IsNil()
+	}
+	return res
+}
+
+func TestServer(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("Server", func() {
+		var ts *httptest.Server
+		g.Before(func() {
+			ts = newTestServer(g)
+		})
+		g.After(func() {
+			ts.Close()
+		})
+		g.It("should report missing releases", func() {
+			e := new(server.Error)
+			res := do(g, http.MethodGet, ts.URL+"/head", nil, nil, e)
+			g.Assert(res.StatusCode).Equal(http.StatusNotFound)
+			g.Assert(e.Code).Equal("no_release")
+			g.Assert(res.Header.Get("ETag")).Equal(`"empty"`)
+		})
+		g.It("should create dev releases", func() {
+			r := new(state.Release)
+			res := do(g, http.MethodPost, ts.URL+"/releases", nil, server.CreateRequest{
+				Versions:    state.VersionMap{"user-service": {Version: "v1.0.0"}},
+				Annotations: state.Annotations{"build": "42"},
+			}, r)
+			g.Assert(res.StatusCode).Equal(http.StatusCreated)
+			g.Assert(r.Kind).Equal(state.ReleaseKindDev)
+			g.Assert(r.Tag).Equal("v0.0.1-dev")
+			g.Assert(res.Header.Get("ETag")).Equal(`"` + r.BlockHash.String() + `"`)
+		})
+		g.It("should reject writes on a moved head", func() {
+			e := new(server.Error)
+			res := do(g, http.MethodPost, ts.URL+"/releases", http.Header{"If-Match": {`"empty"`}}, server.CreateRequest{
+				Versions: state.VersionMap{"user-service": {Version: "v1.1.0"}},
+				Bump:     state.BumpMinor,
+			}, e)
+			g.Assert(res.StatusCode).Equal(http.StatusPreconditionFailed)
+			g.Assert(e.Code).Equal("precondition_failed")
+
+			head := do(g, http.MethodGet, ts.URL+"/head", nil, nil, nil).Header.Get("ETag")
+			r := new(state.Release)
+			res = do(g, http.MethodPost, ts.URL+"/releases", http.Header{"If-Match": {head}}, server.CreateRequest{
+				Versions: state.VersionMap{"user-service": {Version: "v1.1.0"}},
+				Bump:     state.BumpMinor,
+			}, r)
+			g.Assert(res.StatusCode).Equal(http.StatusCreated)
+			g.Assert(r.Tag).Equal("v0.1.0-dev")
+
+			res = do(g, http.MethodGet, ts.URL+"/head", http.Header{"If-None-Match": {res.Header.Get("ETag")}}, nil, nil)
+			g.Assert(res.StatusCode).Equal(http.StatusNotModified)
+		})
+		g.It("should promote and resolve releases", func() {
+			r := new(state.Release)
+			res := do(g, http.MethodPost, ts.URL+"/promote/alpha", nil, server.PromoteRequest{Notes: "first alpha"}, r)
+			g.Assert(res.StatusCode).Equal(http.StatusCreated)
+			g.Assert(r.Tag).Equal("v0.1.0-alpha")
+
+			byTag := new(state.Release)
+			do(g, http.MethodGet, ts.URL+"/releases/v0.1.0-alpha", nil, nil, byTag)
+			g.Assert(byTag.BlockHash).Equal(r.BlockHash)
+			byHash := new(state.Release)
+			do(g, http.MethodGet, ts.URL+"/releases/"+r.BlockHash.Short(), nil, nil, byHash)
+			g.Assert(byHash.Notes).Equal("first alpha")
+		})
+		g.It("should list and diff releases", func() {
+			releases := make([]*state.Release, 0)
+			do(g, http.MethodGet, ts.URL+"/releases?kind=dev&annotation=build=42", nil, nil, &releases)
+			g.Assert(len(releases)).Equal(1)
+			g.Assert(releases[0].Tag).Equal("v0.0.1-dev")
+
+			d := new(server.Diff)
+			do(g, http.MethodGet, ts.URL+"/releases/dev/diff", nil, nil, d)
+			g.Assert(d.From.Tag).Equal("v0.0.1-dev")
+			g.Assert(d.Changes).Equal([]state.VersionChange{{
+				Service: "user-service",
+				From:    state.ServiceVersion{Version: "v1.0.0"},
+				To:      state.ServiceVersion{Version: "v1.1.0"},
+			}})
+		})
+		g.It("should roll back and verify", func() {
+			r := new(state.Release)
+			res := do(g, http.MethodPost, ts.URL+"/rollback", nil, nil, r)
+			g.Assert(res.StatusCode).Equal(http.StatusOK)
+			g.Assert(r.Kind).Equal(state.ReleaseKindAlpha)
+			v := new(server.Verification)
+			do(g, http.MethodGet, ts.URL+"/verify", nil, nil, v)
+			g.Assert(v.OK).IsTrue()
+			g.Assert(v.Full).IsTrue()
+		})
+		g.It("should reject unknown routes", func() {
+			e := new(server.Error)
+			res := do(g, http.MethodDelete, ts.URL+"/releases/dev", nil, nil, e)
+			g.Assert(res.StatusCode).Equal(http.StatusMethodNotAllowed)
+			res = do(g, http.MethodGet, ts.URL+"/unknown", nil, nil, e)
+			g.Assert(res.StatusCode).Equal(http.StatusNotFound)
+			g.Assert(e.Code).Equal("not_found")
+		})
+	})
+}
+
+func TestServer_BeforeSave(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("BeforeSave", func() {
+		g.It("should report failing pre hooks as a conflict", func() {
+			backend, err := server.NewMemoryBackend(nil)
+			g.Assert(err).IsNil()
+			srv := server.New(backend)
+			runner := &hook.Runner{
+				Hooks:  []hook.Hook{{When: hook.Pre, Run: "exit 1"}},
+				Stderr: io.Discard,
+			}
+			srv.BeforeSave = func(s *state.State) error {
+				return runner.Run(hook.Pre, s.Events())
+			}
+			ts := httptest.NewServer(srv)
+			defer ts.Close()
+			e := new(server.Error)
+			res := do(g, http.MethodPost, ts.URL+"/releases", nil, server.CreateRequest{
+				Versions: state.VersionMap{"user-service": {Version: "v1.0.0"}},
+			}, e)
+			g.Assert(res.StatusCode).Equal(http.StatusConflict)
+			g.Assert(e.Code).Equal("hook_failed")
+			res = do(g, http.MethodGet, ts.URL+"/head", nil, nil, e)
+			g.Assert(res.StatusCode).Equal(http.StatusNotFound)
+		})
+	})
+}
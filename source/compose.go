@@ -0,0 +1,192 @@
+package source
+
+import (
+	"bytes"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultComposeFile is the file name docker-compose reads by default.
+const DefaultComposeFile = "docker-compose.yml"
+
+var (
+	ErrVariableUnset        = eris.New("source: variable is not set")
+	ErrInterpolationInvalid = eris.New("source: invalid interpolation")
+)
+
+var variablePattern = regexp.MustCompile(`\$(?:\$|([A-Za-z_][A-Za-z0-9_]*)|\{([A-Za-z_][A-Za-z0-9_]*)(?:(:?[-?])([^}]*))?\})`)
+
+type composeFile struct {
+	Services map[string]struct {
+		Image string `yaml:"image"`
+	} `yaml:"services"`
+}
+
+// Compose builds a version map from the service images of a docker-compose file.
+// Compose services without an image (build only) are skipped.
+// Variables in the images are expanded from the environment, see Interpolate.
+func Compose(path string, mapping Mapping) (state.VersionMap, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f composeFile
+	if err := yaml.Unmarshal(b, &f); err != nil {
+		return nil, eris.Wrapf(err, "source: could not parse %s", path)
+	}
+	names := make([]string, 0, len(f.Services))
+	for name := range f.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	m := state.NewVersionMap()
+	for _, name := range names {
+		svc, ok := mapping.Service("", name)
+		image := f.Services[name].Image
+		if !ok || image == "" {
+			continue
+		}
+		image, err := Interpolate(image, os.LookupEnv)
+		if err != nil {
+			return nil, eris.Wrapf(err, "source: could not read the image of compose service %s in %s", name, path)
+		}
+		if err := put(m, svc, imageVersion(image), "compose service "+name+" in "+path); err != nil {
+			return nil, err
+		}
+	}
+	if len(m) == 0 {
+		return nil, eris.Errorf("source: no service images found in %s", path)
+	}
+	return m, nil
+}
+
+// Interpolate expands the variables of a compose value like docker-compose.
+// It supports $VAR, ${VAR}, ${VAR:-default}, ${VAR-default}, ${VAR:?error}, ${VAR?error} and $$ as escaped $.
+// Unlike docker-compose, a variable without default that is not set is an error
+// instead of an empty string, so no release records an image without a tag.
+func Interpolate(s string, lookup func(name string) (string, bool)) (string, error) {
+	var b strings.Builder
+	last := 0
+	for _, m := range variablePattern.FindAllStringSubmatchIndex(s, -1) {
+		if strings.Contains(s[last:m[0]], "$") {
+			return "", eris.Wrapf(ErrInterpolationInvalid, "source: invalid variable in %q", s)
+		}
+		b.WriteString(s[last:m[0]])
+		last = m[1]
+		name, op, arg := "", "", ""
+		switch {
+		case m[2] >= 0:
+			name = s[m[2]:m[3]]
+		case m[4] >= 0:
+			name = s[m[4]:m[5]]
+			if m[6] >= 0 {
+				op, arg = s[m[6]:m[7]], s[m[8]:m[9]]
+			}
+		default:
+			b.WriteByte('$')
+			continue
+		}
+		value, ok := lookup(name)
+		set := ok && (value != "" || !strings.HasPrefix(op, ":"))
+		switch {
+		case set:
+			b.WriteString(value)
+		case strings.HasSuffix(op, "-"):
+			b.WriteString(arg)
+		case strings.HasSuffix(op, "?"):
+			return "", eris.Wrapf(ErrVariableUnset, "source: %s: %s", name, arg)
+		default:
+			return "", eris.Wrapf(ErrVariableUnset, "source: variable %s of %q is not set", name, s)
+		}
+	}
+	if strings.Contains(s[last:], "$") {
+		return "", eris.Wrapf(ErrInterpolationInvalid, "source: invalid variable in %q", s)
+	}
+	b.WriteString(s[last:])
+	return b.String(), nil
+}
+
+// ImageChange describes a rewritten image of a compose service.
+type ImageChange struct {
+	Service string
+	From    string
+	To      string
+}
+
+// RewriteCompose sets the images of the compose services to the versions of the map.
+// The service image is used if present, otherwise the version replaces the image tag.
+// Comments and the layout of the file are preserved as far as the yaml encoder allows.
+// Service names are matched after normalization with the given rules, usually those of the registry.
+// It returns the rewritten file and the changes.
+func RewriteCompose(b []byte, m state.VersionMap, mapping Mapping, rules state.Normalization) ([]byte, []ImageChange, error) {
+	versions, err := rules.Apply(m)
+	if err != nil {
+		return nil, nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, nil, eris.Wrap(err, "source: could not parse compose file")
+	}
+	services := lookup(&doc, "services")
+	if services == nil || services.Kind != yaml.MappingNode {
+		return nil, nil, eris.New("source: compose file has no services")
+	}
+	changes := make([]ImageChange, 0)
+	for i := 0; i+1 < len(services.Content); i += 2 {
+		name := services.Content[i].Value
+		image := lookup(services.Content[i+1], "image")
+		svc, ok := mapping.Service("", name)
+		if !ok || image == nil {
+			continue
+		}
+		v, ok := versions[rules.NormalizeService(svc)]
+		if !ok {
+			continue
+		}
+		next := v.Image
+		if next == "" {
+			if v.Version == "" {
+				continue
+			}
+			repository, _, _ := ParseImage(image.Value)
+			next = repository + ":" + v.Version
+		}
+		if next == image.Value {
+			continue
+		}
+		changes = append(changes, ImageChange{Service: name, From: image.Value, To: next})
+		image.Value = next
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), changes, nil
+}
+
+// lookup returns the value node of the key in a mapping node, or in the mapping of a document node.
+func lookup(n *yaml.Node, key string) *yaml.Node {
+	if n.Kind == yaml.DocumentNode && len(n.Content) != 0 {
+		n = n.Content[0]
+	}
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
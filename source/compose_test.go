@@ -0,0 +1,128 @@
+package source_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/franela/goblin"
+	"github.com/hsblhsn/microstate/source"
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+)
+
+const compose = `services:
+  # the api
+  api:
+    image: ghcr.io/acme/user:v1.0.0
+  db:
+    image: postgres:14
+  web:
+    build: .
+`
+
+func TestInterpolate(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("Interpolate", func() {
+		env := map[string]string{"TAG": "v1.2.3", "EMPTY": ""}
+		lookup := func(name string) (string, bool) {
+			v, ok := env[name]
+			return v, ok
+		}
+		g.It("should expand the variables", func() {
+			for in, out := range map[string]string{
+				"repo:$TAG":           "repo:v1.2.3",
+				"repo:${TAG}":         "repo:v1.2.3",
+				"repo:${UNSET:-v1}":   "repo:v1",
+				"repo:${EMPTY:-v1}":   "repo:v1",
+				"repo:${EMPTY-v1}":    "repo:",
+				"repo:${TAG:-v1}":     "repo:v1.2.3",
+				"repo:$$TAG":          "repo:$TAG",
+				"ghcr.io/acme/user:1": "ghcr.io/acme/user:1",
+			} {
+				v, err := source.Interpolate(in, lookup)
+				g.Assert(err).IsNil()
+				g.Assert(v).Equal(out)
+			}
+		})
+		g.It("should reject unset and invalid variables", func() {
+			_, err := source.Interpolate("repo:${UNSET}", lookup)
+			g.Assert(eris.Cause(err)).Equal(source.ErrVariableUnset)
+			_, err = source.Interpolate("repo:${EMPTY:?tag is required}", lookup)
+			g.Assert(eris.Cause(err)).Equal(source.ErrVariableUnset)
+			_, err = source.Interpolate("repo:${TAG", lookup)
+			g.Assert(eris.Cause(err)).Equal(source.ErrInterpolationInvalid)
+		})
+	})
+}
+
+func TestCompose(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("Compose", func() {
+		mapping := source.Mapping{"api": "user-service", "db": source.Skip}
+		g.It("should read the service images", func() {
+			dir := t.TempDir()
+			writeFile(g, dir, "docker-compose.yml", compose)
+			m, err := source.Compose(filepath.Join(dir, "docker-compose.yml"), mapping)
+			g.Assert(err).IsNil()
+			g.Assert(m).Equal(state.VersionMap{
+				"user-service": {Version: "v1.0.0", Image: "ghcr.io/acme/user:v1.0.0"},
+			})
+		})
+		g.It("should rewrite the image tags", func() {
+			out, changes, err := source.RewriteCompose([]byte(compose), state.VersionMap{"user-service": {Version: "v1.1.0"}}, mapping, state.DefaultNormalization)
+			g.Assert(err).IsNil()
+			g.Assert(changes).Equal([]source.ImageChange{
+				{Service: "api", From: "ghcr.io/acme/user:v1.0.0", To: "ghcr.io/acme/user:v1.1.0"},
+			})
+			g.Assert(string(out)).Equal(strings.Replace(compose, "user:v1.0.0", "user:v1.1.0", 1))
+		})
+		g.It("should expand the variables of the images", func() {
+			dir := t.TempDir()
+			writeFile(g, dir, "docker-compose.yml", "services:\n  api:\n    image: ${REGISTRY}/acme/user:${TAG:-v1.2.3}\n")
+			t.Setenv("REGISTRY", "ghcr.io")
+			m, err := source.Compose(filepath.Join(dir, "docker-compose.yml"), mapping)
+			g.Assert(err).IsNil()
+			g.Assert(m).Equal(state.VersionMap{
+				"user-service": {Version: "v1.2.3", Image: "ghcr.io/acme/user:v1.2.3"},
+			})
+			t.Setenv("TAG", "v2.0.0")
+			m, err = source.Compose(filepath.Join(dir, "docker-compose.yml"), mapping)
+			g.Assert(err).IsNil()
+			g.Assert(m["user-service"].Version).Equal("v2.0.0")
+		})
+		g.It("should reject unset variables without default", func() {
+			dir := t.TempDir()
+			writeFile(g, dir, "docker-compose.yml", "services:\n  api:\n    image: ghcr.io/acme/user:${MICROSTATE_TEST_UNSET}\n")
+			_, err := source.Compose(filepath.Join(dir, "docker-compose.yml"), mapping)
+			g.Assert(eris.Cause(err)).Equal(source.ErrVariableUnset)
+		})
+		g.It("should rewrite the tag of an interpolated image", func() {
+			b := []byte("services:\n  api:\n    image: ${REGISTRY:-ghcr.io}/acme/user:${TAG:-v1.0.0}\n")
+			out, changes, err := source.RewriteCompose(b, state.VersionMap{"user-service": {Version: "v1.1.0"}}, mapping, state.DefaultNormalization)
+			g.Assert(err).IsNil()
+			g.Assert(changes[0].To).Equal("${REGISTRY:-ghcr.io}/acme/user:v1.1.0")
+			g.Assert(strings.Contains(string(out), "image: ${REGISTRY:-ghcr.io}/acme/user:v1.1.0")).IsTrue()
+		})
+		g.It("should prefer the release image", func() {
+			v := state.ServiceVersion{Version: "v2", Image: "ghcr.io/acme/user@sha256:abc"}
+			out, _, err := source.RewriteCompose([]byte(compose), state.VersionMap{"user-service": v}, mapping, state.DefaultNormalization)
+			g.Assert(err).IsNil()
+			g.Assert(strings.Contains(string(out), "image: ghcr.io/acme/user@sha256:abc")).IsTrue()
+		})
+		g.It("should match mixed case service names with the normalization rules", func() {
+			b := []byte("services:\n  User-Service:\n    image: ghcr.io/acme/user:v1.0.0\n  Gateway:\n    image: ghcr.io/acme/gateway:v1.0.0\n")
+			versions := state.VersionMap{"user-service": {Version: "v1.1.0"}, "Gateway-Service": {Version: "v2.0.0"}}
+			gateway := source.Mapping{"Gateway": "GATEWAY-SERVICE"}
+			_, changes, err := source.RewriteCompose(b, versions, gateway, state.DefaultNormalization)
+			g.Assert(err).IsNil()
+			g.Assert(changes).Equal([]source.ImageChange{
+				{Service: "User-Service", From: "ghcr.io/acme/user:v1.0.0", To: "ghcr.io/acme/user:v1.1.0"},
+				{Service: "Gateway", From: "ghcr.io/acme/gateway:v1.0.0", To: "ghcr.io/acme/gateway:v2.0.0"},
+			})
+			_, changes, err = source.RewriteCompose(b, versions, gateway, state.Normalization{Service: state.CasePreserve})
+			g.Assert(err).IsNil()
+			g.Assert(len(changes)).Equal(0)
+		})
+	})
+}
@@ -0,0 +1,126 @@
+package source
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+	"gopkg.in/yaml.v3"
+)
+
+// podSpec is the subset of a kubernetes pod spec we care about.
+type podSpec struct {
+	Containers []struct {
+		Name  string `yaml:"name"`
+		Image string `yaml:"image"`
+	} `yaml:"containers"`
+}
+
+type podTemplate struct {
+	Spec podSpec `yaml:"spec"`
+}
+
+// workload is the subset of Deployments, StatefulSets, DaemonSets, Jobs and CronJobs we care about.
+type workload struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name string `yaml:"name"`
+	} `yaml:"metadata"`
+	Spec struct {
+		Template    podTemplate `yaml:"template"`
+		JobTemplate struct {
+			Spec struct {
+				Template podTemplate `yaml:"template"`
+			} `yaml:"spec"`
+		} `yaml:"jobTemplate"`
+	} `yaml:"spec"`
+	Items []workload `yaml:"items"`
+}
+
+// containers returns the containers of the pod template of the workload.
+func (w workload) containers() podSpec {
+	if w.Kind == "CronJob" {
+		return w.Spec.JobTemplate.Spec.Template.Spec
+	}
+	return w.Spec.Template.Spec
+}
+
+// Kubernetes builds a version map from the workloads found in the yaml files under dir.
+// Each container image of a Deployment, StatefulSet, DaemonSet, Job or CronJob becomes a service.
+func Kubernetes(dir string, mapping Mapping) (state.VersionMap, error) {
+	files := make([]string, 0)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if ext := filepath.Ext(path); !info.IsDir() && (ext == ".yaml" || ext == ".yml") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, eris.Wrap(err, "source: could not read manifests")
+	}
+	sort.Strings(files)
+	m := state.NewVersionMap()
+	for _, f := range files {
+		if err := readManifest(f, m, mapping); err != nil {
+			return nil, err
+		}
+	}
+	if len(m) == 0 {
+		return nil, eris.Errorf("source: no workloads found in %s", dir)
+	}
+	return m, nil
+}
+
+func readManifest(path string, m state.VersionMap, mapping Mapping) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dec := yaml.NewDecoder(f)
+	for {
+		var w workload
+		err := dec.Decode(&w)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return eris.Wrapf(err, "source: could not parse %s", path)
+		}
+		if err := addWorkload(w, m, mapping, path); err != nil {
+			return err
+		}
+	}
+}
+
+func addWorkload(w workload, m state.VersionMap, mapping Mapping, path string) error {
+	switch w.Kind {
+	case "List":
+		for _, item := range w.Items {
+			if err := addWorkload(item, m, mapping, path); err != nil {
+				return err
+			}
+		}
+	case "Deployment", "StatefulSet", "DaemonSet", "Job", "CronJob":
+		for _, c := range w.containers().Containers {
+			svc, ok := mapping.Service(w.Metadata.Name, c.Name)
+			if !ok || c.Image == "" {
+				continue
+			}
+			origin := fmt.Sprintf("%s %s/%s in %s", strings.ToLower(w.Kind), w.Metadata.Name, c.Name, path)
+			if err := put(m, svc, imageVersion(c.Image), origin); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package source_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/franela/goblin"
+	"github.com/hsblhsn/microstate/source"
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+)
+
+const deployment = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: user
+spec:
+  template:
+    spec:
+      containers:
+        - name: user-service
+          image: ghcr.io/acme/user:v1.2.0
+        - name: istio-proxy
+          image: istio/proxyv2:1.1
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: report
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+            - name: api
+              image: ghcr.io/acme/report@sha256:abc
+`
+
+func writeFile(g *goblin.G, dir, name, content string) {
+	g.Assert(os.WriteFile(filepath.Join(dir, name), []byte(content), os.ModePerm)).IsNil()
+}
+
+func TestKubernetes(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("Kubernetes", func() {
+		g.It("should read the images of the workloads", func() {
+			dir := t.TempDir()
+			writeFile(g, dir, "app.yaml", deployment)
+			m, err := source.Kubernetes(dir, source.Mapping{"istio-proxy": source.Skip, "report/api": "report-service"})
+			g.Assert(err).IsNil()
+			g.Assert(m).Equal(state.VersionMap{
+				"user-service":   {Version: "v1.2.0", Image: "ghcr.io/acme/user:v1.2.0"},
+				"report-service": {Image: "ghcr.io/acme/report@sha256:abc"},
+			})
+		})
+		g.It("should reject conflicting images", func() {
+			dir := t.TempDir()
+			writeFile(g, dir, "app.yaml", deployment)
+			_, err := source.Kubernetes(dir, source.Mapping{"istio-proxy": "user-service"})
+			g.Assert(eris.Cause(err)).Equal(source.ErrServiceConflict)
+		})
+	})
+}
+
+func TestParseImage(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("ParseImage", func() {
+		g.It("should split a reference", func() {
+			repository, tag, digest := source.ParseImage("localhost:5000/acme/user:v1@sha256:abc")
+			g.Assert(repository).Equal("localhost:5000/acme/user")
+			g.Assert(tag).Equal("v1")
+			g.Assert(digest).Equal("sha256:abc")
+		})
+		g.It("should not split compose variables", func() {
+			repository, tag, digest := source.ParseImage("${REGISTRY:-localhost:5000}/acme/user:${TAG:-1.2.3}")
+			g.Assert(repository).Equal("${REGISTRY:-localhost:5000}/acme/user")
+			g.Assert(tag).Equal("${TAG:-1.2.3}")
+			g.Assert(digest).Equal("")
+		})
+		g.It("should accept a registry port without a tag", func() {
+			repository, tag, _ := source.ParseImage("localhost:5000/acme/user")
+			g.Assert(repository).Equal("localhost:5000/acme/user")
+			g.Assert(tag).Equal("")
+		})
+	})
+}
@@ -0,0 +1,104 @@
+// Package source builds service version maps from deployment descriptors
+// like kubernetes manifests and docker-compose files.
+package source
+
+import (
+	"strings"
+
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+)
+
+var (
+	ErrServiceConflict = eris.New("source: service has conflicting images")
+)
+
+// Skip is the mapping target that excludes a container from the version map.
+const Skip = "-"
+
+// Mapping maps container names to service names.
+// Containers without a rule keep their name as service name.
+type Mapping map[string]string
+
+// ParseMapping parses container=service pairs.
+func ParseMapping(pairs []string) (Mapping, error) {
+	m := make(Mapping, len(pairs))
+	for _, v := range pairs {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, eris.Errorf("source: invalid mapping %q, expected container=service", v)
+		}
+		m[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return m, nil
+}
+
+// Service returns the service name of the container.
+// The workload qualified rule (workload/container) takes precedence over the container rule.
+// It returns false if the container is skipped.
+func (m Mapping) Service(workload, container string) (string, bool) {
+	name, ok := m[workload+"/"+container]
+	if !ok {
+		name, ok = m[container]
+	}
+	if !ok {
+		name = container
+	}
+	return name, name != Skip
+}
+
+// ParseImage splits an image reference into the repository, the tag and the digest.
+// e.g. ghcr.io/acme/user:v1.2@sha256:... returns ghcr.io/acme/user, v1.2 and sha256:...
+// Compose variables like ${TAG:-v1.2} are kept as they are and never split.
+func ParseImage(ref string) (repository, tag, digest string) {
+	masked := maskVariables(ref)
+	repository = ref
+	if i := strings.Index(masked, "@"); i >= 0 {
+		repository, digest = ref[:i], ref[i+1:]
+		masked = masked[:i]
+	}
+	if i := strings.LastIndex(masked, ":"); i > strings.LastIndex(masked, "/") {
+		repository, tag = repository[:i], repository[i+1:]
+	}
+	return repository, tag, digest
+}
+
+// maskVariables replaces the contents of ${...} variables with underscores,
+// keeping the positions of the other characters.
+func maskVariables(s string) string {
+	b := []byte(s)
+	for i := 0; i+1 < len(b); i++ {
+		if b[i] != '$' || b[i+1] != '{' {
+			continue
+		}
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			break
+		}
+		for j := i + 2; j < i+end; j++ {
+			b[j] = '_'
+		}
+		i += end
+	}
+	return string(b)
+}
+
+// imageVersion returns the service version of an image reference.
+// The tag becomes the version and the whole reference is kept as the image.
+func imageVersion(ref string) state.ServiceVersion {
+	_, tag, _ := ParseImage(ref)
+	return state.ServiceVersion{
+		Version: tag,
+		Image:   ref,
+	}
+}
+
+// put adds the version to the map.
+// It returns error if the service already exists with a different image.
+func put(m state.VersionMap, svc string, v state.ServiceVersion, origin string) error {
+	if existing, ok := m[svc]; ok && existing != v {
+		return eris.Wrapf(ErrServiceConflict, "source: service %s uses %s and %s (%s)", svc, existing.Image, v.Image, origin)
+	}
+	m[svc] = v
+	return nil
+}
@@ -0,0 +1,56 @@
+package state
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/rotisserie/eris"
+)
+
+// Annotations holds free-form key value pairs attached to a release.
+// e.g. CI build url, commit of the manifest repository or ticket ids.
+type Annotations map[string]string
+
+// ParseAnnotation parses a key=value pair.
+// It returns error if the key is missing.
+func ParseAnnotation(s string) (string, string, error) {
+	parts := strings.SplitN(s, "=", 2)
+	key := strings.TrimSpace(parts[0])
+	if key == "" || len(parts) != 2 {
+		return "", "", eris.Wrapf(ErrAnnotationInvalid, "state: invalid annotation %q, expected key=value", s)
+	}
+	return key, strings.TrimSpace(parts[1]), nil
+}
+
+// Match returns true if all the given annotations are present with the same value.
+func (a Annotations) Match(other Annotations) bool {
+	for k, v := range other {
+		if got, ok := a[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Keys returns the sorted annotation keys.
+func (a Annotations) Keys() []string {
+	keys := make([]string, 0, len(a))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Copy returns a deep copy of the annotations.
+// It returns nil for empty annotations to keep them out of the block hash.
+func (a Annotations) Copy() Annotations {
+	if len(a) == 0 {
+		return nil
+	}
+	newA := make(Annotations, len(a))
+	for k, v := range a {
+		newA[k] = v
+	}
+	return newA
+}
@@ -0,0 +1,123 @@
+package state
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/rotisserie/eris"
+)
+
+// Archive moves the oldest releases matched by the given function to yearly archive files in dir.
+// e.g. .state.archive/2021.json
+// The function is called from the oldest release upwards and archiving stops at the first release
+// it does not match, so the remaining chain stays linked to the newest archived release.
+// The head release is never archived. The move is recorded as a checkpoint.
+// It returns nil if nothing was archived.
+func (s *State) Archive(dir string, match PruneFunc) (*Checkpoint, error) {
+	n := 0
+	for i := len(s.Releases) - 1; i > 0; i-- {
+		if !match(i, s.Releases[i]) {
+			break
+		}
+		n++
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	archived := s.Releases[len(s.Releases)-n:]
+	byYear := make(map[int][]*Release)
+	for _, v := range archived {
+		year := v.CreatedAt.Year()
+		byYear[year] = append(byYear[year], v)
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, eris.Wrap(err, "state: could not create archive directory")
+	}
+	c := &Checkpoint{
+		CreatedAt: s.now(),
+		Reason:    "archive",
+	}
+	for _, v := range archived {
+		c.Archived = append(c.Archived, v.BlockHash)
+	}
+	years := make([]int, 0, len(byYear))
+	for year := range byYear {
+		years = append(years, year)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(years)))
+	for _, year := range years {
+		name := strconv.Itoa(year) + ".json"
+		if err := appendArchive(filepath.Join(dir, name), byYear[year]); err != nil {
+			return nil, err
+		}
+		c.Archives = append(c.Archives, name)
+	}
+	s.Releases = s.Releases[:len(s.Releases)-n]
+	if err := s.addCheckpoint(c); err != nil {
+		return nil, err
+	}
+	s.emitChange(EventChainArchived, c)
+	return c, nil
+}
+
+// appendArchive adds the releases to the top of the archive file.
+// Releases already present in the archive are skipped.
+func appendArchive(path string, releases []*Release) error {
+	archive := NewState()
+	if b, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(b, archive); err != nil {
+			return eris.Wrapf(err, "state: could not read archive %s", path)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	merged := make([]*Release, 0, len(releases)+len(archive.Releases))
+	for _, v := range releases {
+		if archive.IndexOf(v.BlockHash) < 0 {
+			merged = append(merged, v)
+		}
+	}
+	archive.Releases = append(merged, archive.Releases...)
+	return archive.Export(path)
+}
+
+// LoadArchive returns all the archived releases found in dir in stack order, newest release first.
+// It returns an empty list if the directory does not exist.
+func LoadArchive(dir string) ([]*Release, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(files)))
+	releases := make([]*Release, 0)
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		archive := NewState()
+		if err := json.Unmarshal(b, archive); err != nil {
+			return nil, eris.Wrapf(err, "state: could not read archive %s", f)
+		}
+		releases = append(releases, archive.Releases...)
+	}
+	return releases, nil
+}
+
+// ValidateWithArchive validates the whole chain including the releases archived in dir.
+func (s *State) ValidateWithArchive(dir string) error {
+	archived, err := LoadArchive(dir)
+	if err != nil {
+		return err
+	}
+	releases := make([]*Release, 0, len(s.Releases)+len(archived))
+	releases = append(releases, s.Releases...)
+	releases = append(releases, archived...)
+	if err := validateReleases(releases); err != nil {
+		return err
+	}
+	return s.validateCheckpoints()
+}
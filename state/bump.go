@@ -0,0 +1,49 @@
+package state
+
+import (
+	"github.com/Masterminds/semver/v3"
+	"github.com/rotisserie/eris"
+)
+
+var (
+	ErrBumpInvalid = eris.New("state: version bump is invalid")
+)
+
+// Bump is the part of the version a new dev release increments.
+type Bump string
+
+const (
+	BumpMajor Bump = "major"
+	BumpMinor Bump = "minor"
+	BumpPatch Bump = "patch"
+)
+
+// NextDevTag returns the tag of the next dev release.
+// The empty bump increments the patch version.
+func (s *State) NextDevTag(bump Bump) (string, error) {
+	latest, err := semver.NewVersion(s.Latest(ReleaseKindDev).Tag)
+	if err != nil {
+		return "", eris.Wrap(err, "state: could not parse latest dev release version")
+	}
+	// IncPatch only drops the prerelease of a prerelease version
+	released, err := latest.SetPrerelease("")
+	if err != nil {
+		return "", eris.Wrap(err, "state: could not parse latest dev release version")
+	}
+	var next semver.Version
+	switch bump {
+	case BumpMajor:
+		next = released.IncMajor()
+	case BumpMinor:
+		next = released.IncMinor()
+	case BumpPatch, "":
+		next = released.IncPatch()
+	default:
+		return "", eris.Wrapf(ErrBumpInvalid, "state: unknown version bump %q", bump)
+	}
+	next, err = next.SetPrerelease(ReleaseKindDev.String())
+	if err != nil {
+		return "", eris.Wrap(err, "state: could not set prerelease info on version tag")
+	}
+	return "v" + next.String(), nil
+}
@@ -0,0 +1,34 @@
+package state_test
+
+import (
+	"testing"
+
+	"github.com/franela/goblin"
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+)
+
+func TestState_NextDevTag(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("NextDevTag", func() {
+		g.It("should bump past the latest dev release", func() {
+			s := newTestState()
+			for _, expected := range []string{"v0.0.1-dev", "v0.0.2-dev"} {
+				tag, err := s.NextDevTag(state.BumpPatch)
+				g.Assert(err).IsNil()
+				g.Assert(tag).Equal(expected)
+				g.Assert(s.CreateRelease(newTestRelease(g, tag, state.VersionMap{"a": {Version: tag}}))).IsNil()
+			}
+			tag, err := s.NextDevTag(state.BumpMinor)
+			g.Assert(err).IsNil()
+			g.Assert(tag).Equal("v0.1.0-dev")
+			tag, err = s.NextDevTag(state.BumpMajor)
+			g.Assert(err).IsNil()
+			g.Assert(tag).Equal("v1.0.0-dev")
+		})
+		g.It("should reject an unknown bump", func() {
+			_, err := newTestState().NextDevTag("build")
+			g.Assert(eris.Cause(err)).Equal(state.ErrBumpInvalid)
+		})
+	})
+}
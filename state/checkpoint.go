@@ -0,0 +1,116 @@
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/rotisserie/eris"
+)
+
+// Checkpoint commits to the release chain up to its head block.
+// Since every block hash includes the previous block hash,
+// the head hash is a commitment to everything before it.
+// Checkpoints also record rewrites of the chain,
+// pruning blocks from the middle of the chain changes the hashes of the newer blocks,
+// the checkpoint keeps track of what was removed and how the hashes changed.
+type Checkpoint struct {
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	// Head is the block hash of the head release at the time of the checkpoint.
+	Head Hash `json:"head,omitempty"`
+	// Count is the number of releases in the state at the time of the checkpoint.
+	Count int `json:"count,omitempty"`
+	// Pruned holds the block hashes of the removed releases.
+	Pruned []Hash `json:"pruned,omitempty"`
+	// Rechained maps the old block hashes of the re-linked releases to the new ones.
+	Rechained map[Hash]Hash `json:"rechained,omitempty"`
+	// Archived holds the block hashes of the releases moved to archive files.
+	Archived []Hash `json:"archived,omitempty"`
+	// Archives holds the names of the archive files the releases were moved to.
+	Archives []string `json:"archives,omitempty"`
+	Hash     Hash     `json:"hash,omitempty"`
+}
+
+// ComputeHash returns the hash of the checkpoint content.
+func (c Checkpoint) ComputeHash() (Hash, error) {
+	c.Hash = ""
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write(b)
+	return Hash(hex.EncodeToString(h.Sum(nil))), nil
+}
+
+// Checkpoint creates a checkpoint for the current head release.
+func (s *State) Checkpoint(reason string) (*Checkpoint, error) {
+	if len(s.Releases) == 0 {
+		return nil, ErrNoRelease
+	}
+	c := &Checkpoint{
+		CreatedAt: s.now(),
+		Reason:    reason,
+	}
+	if err := s.addCheckpoint(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// LatestCheckpoint returns the latest checkpoint whose head is still part of the chain
+// with the number of releases it counted. It returns nil if there is no such checkpoint.
+//
+// Checkpoints are stored in the state file and hashed over its own content, anyone able
+// to edit the file can forge them. They only save work, releases below the checkpoint head
+// are not re-hashed, and are no integrity guarantee. Use Validate to re-hash the whole chain.
+func (s *State) LatestCheckpoint() *Checkpoint {
+	for _, c := range s.Checkpoints {
+		if c.Head.IsEmpty() {
+			continue
+		}
+		if i := s.IndexOf(c.Head); i >= 0 && len(s.Releases)-i == c.Count {
+			return c
+		}
+	}
+	return nil
+}
+
+// sinceCheckpoint returns the number of releases created after the latest checkpoint.
+func (s *State) sinceCheckpoint() int {
+	c := s.LatestCheckpoint()
+	if c == nil {
+		return len(s.Releases)
+	}
+	return s.IndexOf(c.Head)
+}
+
+// addCheckpoint points the checkpoint to the current head, hashes it and prepends it to the state.
+func (s *State) addCheckpoint(c *Checkpoint) error {
+	c.Count = len(s.Releases)
+	if len(s.Releases) != 0 {
+		c.Head = s.Releases[0].BlockHash
+	}
+	hash, err := c.ComputeHash()
+	if err != nil {
+		return err
+	}
+	c.Hash = hash
+	s.Checkpoints = append([]*Checkpoint{c}, s.Checkpoints...)
+	return nil
+}
+
+func (s *State) validateCheckpoints() error {
+	for _, c := range s.Checkpoints {
+		hash, err := c.ComputeHash()
+		if err != nil {
+			return err
+		}
+		if !c.Hash.Match(hash) {
+			return eris.Errorf("state: checkpoint %s is corrupted. calculated hash %s", c.Hash.Short(), hash.String())
+		}
+	}
+	return nil
+}
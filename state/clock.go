@@ -0,0 +1,28 @@
+package state
+
+import (
+	"time"
+)
+
+// Clock tells the current time.
+// State uses it to timestamp new releases.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is a clock backed by time.Now.
+type SystemClock struct{}
+
+// Now returns the current local time.
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// FixedClock is a clock that always returns the same time.
+// It makes release timestamps and therefore block hashes reproducible.
+type FixedClock time.Time
+
+// Now returns the fixed time.
+func (c FixedClock) Now() time.Time {
+	return time.Time(c)
+}
@@ -1,5 +1,8 @@
 package state
 
 const (
-	DefaultFileName = "./.state.json"
+	DefaultFileName           = "./.state.json"
+	DefaultArchiveDir         = "./.state.archive"
+	DefaultRegistryFileName   = "./.services.json"
+	DefaultCheckpointInterval = 100
 )
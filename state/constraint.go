@@ -0,0 +1,190 @@
+package state
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/rotisserie/eris"
+)
+
+var (
+	ErrConstraintInvalid  = eris.New("state: constraint is invalid")
+	ErrConstraintViolated = eris.New("state: release violates constraints")
+)
+
+var constraintPattern = regexp.MustCompile(`^\s*([a-zA-Z0-9][a-zA-Z0-9._-]*)\s*([^a-zA-Z0-9\s].*?)?\s+(?i:requires)\s+([a-zA-Z0-9][a-zA-Z0-9._-]*)\s*([^a-zA-Z0-9\s].*?)?\s*$`)
+
+// Constraint declares that a version range of a service requires a version range of another service.
+// e.g. gateway-service>=2.3 requires user-service>=1.8
+type Constraint struct {
+	Service string
+	// Range of the service the constraint applies to. Nil matches every version.
+	Range    *semver.Constraints
+	Requires string
+	// RequiresRange is the required version range. Nil only requires the service to be present.
+	RequiresRange *semver.Constraints
+	raw           string
+}
+
+// ParseConstraint parses a constraint declaration.
+// The service names are kept as written, see Normalize.
+func ParseConstraint(s string) (*Constraint, error) {
+	parts := constraintPattern.FindStringSubmatch(s)
+	if parts == nil {
+		return nil, eris.Wrapf(ErrConstraintInvalid, "state: could not parse constraint %q, expected <service><range> requires <service><range>", s)
+	}
+	c := &Constraint{
+		Service:  parts[1],
+		Requires: parts[3],
+		raw:      strings.TrimSpace(s),
+	}
+	var err error
+	if c.Range, err = parseRange(parts[2]); err != nil {
+		return nil, eris.Wrapf(ErrConstraintInvalid, "state: invalid range of %s in %q: %v", c.Service, s, err)
+	}
+	if c.RequiresRange, err = parseRange(parts[4]); err != nil {
+		return nil, eris.Wrapf(ErrConstraintInvalid, "state: invalid range of %s in %q: %v", c.Requires, s, err)
+	}
+	return c, nil
+}
+
+func parseRange(s string) (*semver.Constraints, error) {
+	if s = strings.TrimSpace(s); s == "" {
+		return nil, nil
+	}
+	return semver.NewConstraint(s)
+}
+
+// Normalize applies the service name rule to both services of the constraint.
+func (c *Constraint) Normalize(rules Normalization) *Constraint {
+	c.Service = rules.NormalizeService(c.Service)
+	c.Requires = rules.NormalizeService(c.Requires)
+	return c
+}
+
+// String returns the constraint declaration.
+func (c *Constraint) String() string {
+	return c.raw
+}
+
+// Check returns a description of the violation or an empty string if the versions satisfy the constraint.
+func (c *Constraint) Check(m VersionMap) string {
+	entry, ok := m[c.Service]
+	if !ok {
+		return ""
+	}
+	version := entry.Primary()
+	if c.Range != nil {
+		v, err := semver.NewVersion(version)
+		if err != nil {
+			return fmt.Sprintf("%s@%s is not a semantic version", c.Service, version)
+		}
+		if !c.Range.Check(v) {
+			return ""
+		}
+	}
+	requiredEntry, ok := m[c.Requires]
+	required := requiredEntry.Primary()
+	if !ok {
+		return fmt.Sprintf("%s@%s requires %s, but it is not part of the release", c.Service, version, c.Requires)
+	}
+	if c.RequiresRange == nil {
+		return ""
+	}
+	v, err := semver.NewVersion(required)
+	if err != nil {
+		return fmt.Sprintf("%s@%s requires %s %s, but %s is not a semantic version", c.Service, version, c.Requires, c.RequiresRange, required)
+	}
+	if !c.RequiresRange.Check(v) {
+		return fmt.Sprintf("%s@%s requires %s %s, found %s", c.Service, version, c.Requires, c.RequiresRange, required)
+	}
+	return ""
+}
+
+// ConstraintViolation describes a constraint a release does not satisfy.
+type ConstraintViolation struct {
+	Constraint string
+	Reason     string
+}
+
+// ConstraintError is returned when a release violates declared constraints.
+type ConstraintError struct {
+	Violations []ConstraintViolation
+}
+
+// Error returns a report of all the violations.
+func (e *ConstraintError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "state: release violates %d constraint(s):", len(e.Violations))
+	for _, v := range e.Violations {
+		fmt.Fprintf(&b, "\n  - %s: %s", v.Constraint, v.Reason)
+	}
+	return b.String()
+}
+
+// Unwrap returns ErrConstraintViolated, so errors.Is matches any ConstraintError.
+func (e *ConstraintError) Unwrap() error {
+	return ErrConstraintViolated
+}
+
+// CheckConstraints returns a ConstraintError if the versions violate any declared constraint.
+func (reg *Registry) CheckConstraints(m VersionMap) error {
+	if reg == nil {
+		return nil
+	}
+	violations := make([]ConstraintViolation, 0)
+	for _, s := range reg.Constraints {
+		c, err := reg.parseConstraint(s)
+		if err != nil {
+			return err
+		}
+		if reason := c.Check(m); reason != "" {
+			violations = append(violations, ConstraintViolation{
+				Constraint: c.String(),
+				Reason:     reason,
+			})
+		}
+	}
+	if len(violations) != 0 {
+		return &ConstraintError{Violations: violations}
+	}
+	return nil
+}
+
+// AddConstraint declares a new constraint.
+func (reg *Registry) AddConstraint(s string) error {
+	c, err := reg.parseConstraint(s)
+	if err != nil {
+		return err
+	}
+	for _, v := range reg.Constraints {
+		if v == c.String() {
+			return nil
+		}
+	}
+	reg.Constraints = append(reg.Constraints, c.String())
+	return nil
+}
+
+// parseConstraint parses the constraint and normalizes its service names with the rules of the registry.
+func (reg *Registry) parseConstraint(s string) (*Constraint, error) {
+	c, err := ParseConstraint(s)
+	if err != nil {
+		return nil, err
+	}
+	return c.Normalize(reg.Rules()), nil
+}
+
+// RemoveConstraint removes a declared constraint.
+func (reg *Registry) RemoveConstraint(s string) error {
+	s = strings.TrimSpace(s)
+	for i, v := range reg.Constraints {
+		if v == s {
+			reg.Constraints = append(reg.Constraints[:i], reg.Constraints[i+1:]...)
+			return nil
+		}
+	}
+	return eris.Wrapf(ErrConstraintInvalid, "state: constraint %q is not declared", s)
+}
@@ -0,0 +1,64 @@
+package state_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/franela/goblin"
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+)
+
+func TestConstraint(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("Constraint", func() {
+		g.It("should parse a declaration", func() {
+			c, err := state.ParseConstraint("gateway-service>=2.3 requires user-service>=1.8")
+			g.Assert(err).IsNil()
+			g.Assert(c.Service).Equal("gateway-service")
+			g.Assert(c.Requires).Equal("user-service")
+			g.Assert(c.Range.String()).Equal(">=2.3")
+			g.Assert(c.RequiresRange.String()).Equal(">=1.8")
+		})
+		g.It("should reject an invalid declaration", func() {
+			_, err := state.ParseConstraint("gateway-service>=2.3 needs user-service")
+			g.Assert(eris.Cause(err)).Equal(state.ErrConstraintInvalid)
+		})
+		g.It("should only apply to the declared range", func() {
+			c, _ := state.ParseConstraint("gateway-service>=2.3 requires user-service>=1.8")
+			g.Assert(c.Check(state.VersionMap{"gateway-service": {Version: "2.2.0"}, "user-service": {Version: "1.0.0"}})).Equal("")
+			g.Assert(c.Check(state.VersionMap{"gateway-service": {Version: "2.3.0"}, "user-service": {Version: "1.8.1"}})).Equal("")
+			g.Assert(c.Check(state.VersionMap{"gateway-service": {Version: "2.3.0"}, "user-service": {Version: "1.7.0"}}) != "").IsTrue()
+			g.Assert(c.Check(state.VersionMap{"gateway-service": {Version: "2.3.0"}}) != "").IsTrue()
+		})
+		g.It("should check the primary version of structured entries", func() {
+			c, _ := state.ParseConstraint("gateway-service>=2.3 requires user-service>=1.8")
+			g.Assert(c.Check(state.VersionMap{"gateway-service": {SHA: "3db20cf"}, "user-service": {Version: "1.8.0"}})).
+				Equal("gateway-service@3db20cf is not a semantic version")
+			g.Assert(c.Check(state.VersionMap{"gateway-service": {Version: "2.3.0"}, "user-service": {SHA: "4ca603f"}})).
+				Equal("gateway-service@2.3.0 requires user-service >=1.8, but 4ca603f is not a semantic version")
+		})
+		g.It("should normalize service names with the rules of the registry", func() {
+			versions := state.VersionMap{"Gateway-Service": {Version: "2.4.0"}, "User-Service": {Version: "1.7.0"}}
+			reg := state.NewRegistry()
+			reg.Normalization = &state.Normalization{Service: state.CasePreserve}
+			g.Assert(reg.AddConstraint("Gateway-Service>=2.3 REQUIRES User-Service>=1.8")).IsNil()
+			g.Assert(errors.Is(reg.CheckConstraints(versions), state.ErrConstraintViolated)).IsTrue()
+
+			reg = state.NewRegistry()
+			g.Assert(reg.AddConstraint("Gateway-Service>=2.3 requires User-Service>=1.8")).IsNil()
+			g.Assert(reg.CheckConstraints(versions)).IsNil()
+			g.Assert(errors.Is(reg.CheckConstraints(state.VersionMap{"gateway-service": {Version: "2.4.0"}, "user-service": {Version: "1.7.0"}}), state.ErrConstraintViolated)).IsTrue()
+		})
+		g.It("should be checked on release creation", func() {
+			s := newTestState()
+			s.Registry = state.NewRegistry()
+			g.Assert(s.Registry.AddConstraint("gateway-service>=2.3 requires user-service>=1.8")).IsNil()
+			r := newTestRelease(g, "v1.0.0-dev", state.VersionMap{"gateway-service": {Version: "2.4.0"}, "user-service": {Version: "1.7.0"}})
+			var cerr *state.ConstraintError
+			g.Assert(errors.As(s.CreateRelease(r), &cerr)).IsTrue()
+			g.Assert(len(cerr.Violations)).Equal(1)
+			g.Assert(len(s.Releases)).Equal(0)
+		})
+	})
+}
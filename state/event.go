@@ -0,0 +1,169 @@
+package state
+
+import (
+	"strings"
+	"time"
+
+	"github.com/rotisserie/eris"
+)
+
+// EventType is the type of a ledger change.
+type EventType string
+
+const (
+	// EventReleaseCreated is emitted for releases created by CreateRelease.
+	EventReleaseCreated EventType = "release.created"
+	// EventReleasePromoted is emitted for releases created by Promote.
+	EventReleasePromoted EventType = "release.promoted"
+	// EventReleaseRolledBack is emitted for releases removed by Rollback.
+	EventReleaseRolledBack EventType = "release.rolled_back"
+	// EventChainPruned is emitted for releases removed by Prune.
+	EventChainPruned EventType = "chain.pruned"
+	// EventChainRewritten is emitted for releases changed by Rewrite, e.g. by Normalize.
+	EventChainRewritten EventType = "chain.rewritten"
+	// EventChainArchived is emitted for releases moved to archive files by Archive.
+	EventChainArchived EventType = "chain.archived"
+	// EventChainMerged is emitted for releases re-linked by Merge.
+	EventChainMerged EventType = "chain.merged"
+)
+
+var (
+	ErrChainRewritten = eris.New("state: chain was rewritten")
+)
+
+// EventTypes lists all the event types.
+var EventTypes = []EventType{
+	EventReleaseCreated,
+	EventReleasePromoted,
+	EventReleaseRolledBack,
+	EventChainPruned,
+	EventChainRewritten,
+	EventChainArchived,
+	EventChainMerged,
+}
+
+// Event describes a change of the state.
+type Event struct {
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+	// Release is the created, promoted or removed release.
+	// Events of chain changes carry the head release after the change,
+	// or nil if the change left the ledger empty.
+	Release *Release `json:"release,omitempty"`
+	// From is the release a promoted release was promoted from.
+	From *Release `json:"from,omitempty"`
+	// Checkpoint records the removed and re-linked releases of a chain change.
+	Checkpoint *Checkpoint `json:"checkpoint,omitempty"`
+}
+
+// Events returns the events of the changes made to the state since it was created.
+// Events are not persisted, callers publish them once the state is saved.
+func (s *State) Events() []Event {
+	return s.events
+}
+
+// Key identifies the event by its type, release and checkpoint.
+// It is stable across replays and deliveries of the same event.
+func (e Event) Key() string {
+	key := string(e.Type)
+	if e.Release != nil {
+		key += e.Release.BlockHash.String()
+	}
+	if e.Checkpoint != nil {
+		key += e.Checkpoint.Hash.String()
+	}
+	return key
+}
+
+// emit records an event. Events of created releases take the time of the release.
+func (s *State) emit(t EventType, r, from *Release) {
+	e := Event{
+		Type:    t,
+		Time:    r.CreatedAt,
+		Release: r.Copy(),
+	}
+	if t == EventReleaseRolledBack {
+		e.Time = s.now()
+	}
+	if from != nil {
+		e.From = from.Copy()
+	}
+	s.events = append(s.events, e)
+}
+
+// emitChange records the event of a chain change recorded by the checkpoint.
+func (s *State) emitChange(t EventType, c *Checkpoint) {
+	var head *Release
+	if len(s.Releases) != 0 {
+		head = s.Releases[0].Copy()
+	}
+	checkpoint := *c
+	s.events = append(s.events, Event{
+		Type:       t,
+		Time:       c.CreatedAt,
+		Release:    head,
+		Checkpoint: &checkpoint,
+	})
+}
+
+// Replay returns the events of the releases created after the release of the given hash, oldest first.
+// The hash may be abbreviated, an empty hash replays the whole chain.
+// Rollbacks and chain changes are not part of the chain and are not replayed. Replaying from a release
+// that was removed or re-linked by a chain change fails with ErrChainRewritten, the consumer holds
+// releases that are no longer part of the chain and has to replay the whole chain.
+func (s *State) Replay(since Hash) ([]Event, error) {
+	end := len(s.Releases)
+	if !since.IsEmpty() {
+		if c := s.rewrittenBy(since); c != nil {
+			return nil, eris.Wrapf(ErrChainRewritten, "state: release %s was rewritten by %q at %s", since, c.Reason, c.CreatedAt.Format(time.RFC3339))
+		}
+		end = -1
+		for i, v := range s.Releases {
+			if strings.HasPrefix(v.BlockHash.String(), since.String()) {
+				end = i
+				break
+			}
+		}
+		if end == -1 {
+			return nil, eris.Wrapf(ErrReleaseNotFound, "state: release %s is not part of the chain", since)
+		}
+	}
+	events := make([]Event, 0, end)
+	for i := end - 1; i >= 0; i-- {
+		r := s.Releases[i]
+		e := Event{
+			Type:    EventReleaseCreated,
+			Time:    r.CreatedAt,
+			Release: r.Copy(),
+		}
+		if prev, err := r.Kind.Prev(); err == nil {
+			e.Type = EventReleasePromoted
+			for _, v := range s.Releases[i+1:] {
+				if v.Kind.Is(prev) {
+					e.From = v.Copy()
+					break
+				}
+			}
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// rewrittenBy returns the checkpoint of the chain change that pruned or re-linked the release of the hash.
+// It returns nil if the release was not rewritten.
+func (s *State) rewrittenBy(h Hash) *Checkpoint {
+	for _, c := range s.Checkpoints {
+		for old := range c.Rechained {
+			if strings.HasPrefix(old.String(), h.String()) {
+				return c
+			}
+		}
+		for _, old := range c.Pruned {
+			if strings.HasPrefix(old.String(), h.String()) {
+				return c
+			}
+		}
+	}
+	return nil
+}
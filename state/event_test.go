@@ -0,0 +1,128 @@
+package state_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/franela/goblin"
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+)
+
+func TestState_Replay(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("Replay", func() {
+		build := func() *state.State {
+			s := newTestState()
+			g.Assert(s.CreateRelease(newTestRelease(g, "v1.0.0-dev", state.VersionMap{"a": {Version: "1"}}))).IsNil()
+			g.Assert(s.PromoteTo(state.ReleaseKindAlpha)).IsNil()
+			g.Assert(s.CreateRelease(newTestRelease(g, "v1.0.1-dev", state.VersionMap{"a": {Version: "2"}}))).IsNil()
+			return s
+		}
+		g.It("should replay the whole chain oldest first", func() {
+			s := build()
+			events, err := s.Replay("")
+			g.Assert(err).IsNil()
+			g.Assert(len(events)).Equal(3)
+			g.Assert(events[0].Type).Equal(state.EventReleaseCreated)
+			g.Assert(events[0].Release.Tag).Equal("v1.0.0-dev")
+			g.Assert(events[1].Type).Equal(state.EventReleasePromoted)
+			g.Assert(events[1].From.Tag).Equal("v1.0.0-dev")
+			g.Assert(events[2].Release.BlockHash).Equal(s.Releases[0].BlockHash)
+		})
+		g.It("should replay the releases after the given hash", func() {
+			s := build()
+			events, err := s.Replay(state.Hash(s.Releases[1].BlockHash.Short()))
+			g.Assert(err).IsNil()
+			g.Assert(len(events)).Equal(1)
+			g.Assert(events[0].Release.Tag).Equal("v1.0.1-dev")
+			events, err = s.Replay(s.Releases[0].BlockHash)
+			g.Assert(err).IsNil()
+			g.Assert(len(events)).Equal(0)
+		})
+		g.It("should reject replays from rewritten releases", func() {
+			s := build()
+			g.Assert(s.CreateRelease(newTestRelease(g, "v1.0.2-dev", state.VersionMap{"a": {Version: "3"}}))).IsNil()
+			old := s.Releases[1].BlockHash
+			c, err := s.Prune(func(i int, _ *state.Release) bool { return i == 2 }, "test")
+			g.Assert(err).IsNil()
+			g.Assert(c.Rechained[old].IsEmpty()).IsFalse()
+			_, err = s.Replay(old)
+			g.Assert(eris.Is(err, state.ErrChainRewritten)).IsTrue()
+			_, err = s.Replay(c.Pruned[0])
+			g.Assert(eris.Is(err, state.ErrChainRewritten)).IsTrue()
+			// releases below the pruned one are not rewritten
+			events, err := s.Replay(s.Releases[2].BlockHash)
+			g.Assert(err).IsNil()
+			g.Assert(len(events)).Equal(2)
+		})
+		g.It("should fail for an unknown hash", func() {
+			_, err := build().Replay("0123456789abcdef")
+			g.Assert(err).IsNotNil()
+		})
+	})
+}
+
+func TestState_Events(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("Events", func() {
+		build := func() *state.State {
+			s := newTestState()
+			for _, tag := range []string{"v1.0.0-dev", "v1.0.1-dev", "v1.0.2-dev"} {
+				g.Assert(s.CreateRelease(newTestRelease(g, tag, state.VersionMap{"a": {Version: tag}}))).IsNil()
+			}
+			loaded := state.NewState()
+			b, err := s.Marshal()
+			g.Assert(err).IsNil()
+			g.Assert(loaded.Load(b)).IsNil()
+			loaded.Clock = s.Clock
+			return loaded
+		}
+		last := func(s *state.State) state.Event {
+			events := s.Events()
+			g.Assert(len(events)).Equal(1)
+			return events[0]
+		}
+		g.It("should be emitted for pruned releases", func() {
+			s := build()
+			c, err := s.Prune(func(i int, _ *state.Release) bool { return i == 1 }, "test")
+			g.Assert(err).IsNil()
+			e := last(s)
+			g.Assert(e.Type).Equal(state.EventChainPruned)
+			g.Assert(e.Release.BlockHash).Equal(s.Releases[0].BlockHash)
+			g.Assert(e.Checkpoint.Hash).Equal(c.Hash)
+			g.Assert(e.Time.Equal(c.CreatedAt)).IsTrue()
+		})
+		g.It("should carry no release if the chain change left the ledger empty", func() {
+			s := build()
+			_, err := s.Prune(func(int, *state.Release) bool { return true }, "test")
+			g.Assert(err).IsNil()
+			e := last(s)
+			g.Assert(e.Release == nil).IsTrue()
+			b, err := json.Marshal(e)
+			g.Assert(err).IsNil()
+			g.Assert(strings.Contains(string(b), `"release"`)).IsFalse()
+		})
+		g.It("should be emitted for rewritten releases", func() {
+			s := build()
+			c, err := s.Rewrite(func(r *state.Release) error {
+				r.Notes = "rewritten"
+				return nil
+			}, "test")
+			g.Assert(err).IsNil()
+			g.Assert(c == nil).IsFalse()
+			e := last(s)
+			g.Assert(e.Type).Equal(state.EventChainRewritten)
+			g.Assert(len(e.Checkpoint.Rechained)).Equal(3)
+		})
+		g.It("should be emitted for archived releases", func() {
+			s := build()
+			_, err := s.Archive(t.TempDir(), func(i int, _ *state.Release) bool { return true })
+			g.Assert(err).IsNil()
+			e := last(s)
+			g.Assert(e.Type).Equal(state.EventChainArchived)
+			g.Assert(len(e.Checkpoint.Archived)).Equal(2)
+		})
+	})
+}
@@ -0,0 +1,20 @@
+package state
+
+// Ledger is the read and write interface of a release ledger.
+// It is implemented by State and by the client of the http api.
+type Ledger interface {
+	// LatestE returns the latest release of the given kind,
+	// or a blank release with tag v0.0.0 if there is none.
+	// Remote ledgers return an error if the ledger can not be read.
+	LatestE(kind ReleaseKind) (*Release, error)
+	// Head returns the latest release.
+	Head() (*Release, error)
+	// GetRelease returns the release of the given block hash.
+	GetRelease(hash Hash) (*Release, error)
+	// CreateRelease creates the release on top of the ledger.
+	CreateRelease(r *Release) error
+	// Promote promotes the latest release of the given kind to the next kind.
+	Promote(from ReleaseKind, opts ...ReleaseOption) error
+}
+
+var _ Ledger = (*State)(nil)
@@ -0,0 +1,217 @@
+package state
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/rotisserie/eris"
+)
+
+var (
+	ErrMergeConflict = eris.New("state: states can not be merged")
+)
+
+// MergePolicy controls which releases Merge may re-link.
+type MergePolicy struct {
+	// Rechain lists the release kinds that may be re-linked and re-hashed.
+	// Re-linking changes the block hash, kinds whose hashes are referenced
+	// elsewhere (e.g. tagged ga releases) should not be listed.
+	Rechain []ReleaseKind
+}
+
+// DefaultMergePolicy re-links dev releases only.
+var DefaultMergePolicy = MergePolicy{
+	Rechain: []ReleaseKind{ReleaseKindDev},
+}
+
+// Allows reports whether releases of the given kind may be re-linked.
+func (p MergePolicy) Allows(kind ReleaseKind) bool {
+	for _, v := range p.Rechain {
+		if v.Is(kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// Merge performs a chain-aware three-way merge of two states diverged from the base state.
+// Both states must share the chain up to a common release. If both sides created releases
+// on top of it, the side whose first new release is older is kept and the new releases of the
+// other side are re-linked onto it and re-hashed, which the policy must allow for their kinds.
+// Re-linked releases whose tag is already taken get the next free patch version of their kind.
+// The re-link is recorded as a checkpoint. Checkpoints of both sides are kept.
+// If one side did not change since the base, the other side is taken as is.
+func Merge(base, ours, theirs *State, policy MergePolicy) (*State, error) {
+	for name, s := range map[string]*State{"base": base, "ours": ours, "theirs": theirs} {
+		if err := s.Validate(); err != nil {
+			return nil, eris.Wrapf(err, "state: %s is invalid", name)
+		}
+	}
+	// a side that did not change since the base takes any rewrite of the other side
+	if sameHead(base, ours) {
+		return theirs, nil
+	}
+	if sameHead(base, theirs) {
+		return ours, nil
+	}
+	oursNew, theirsNew, err := diverged(ours, theirs)
+	if err != nil {
+		return nil, err
+	}
+	result := NewState()
+	result.Clock = ours.Clock
+	result.Checkpoints = mergeCheckpoints(ours, theirs)
+	kept, added := ours, theirsNew
+	switch {
+	case len(theirsNew) == 0:
+		result.Releases = ours.Releases
+		return result, nil
+	case len(oursNew) == 0:
+		result.Releases = theirs.Releases
+		return result, nil
+	case theirsNew[len(theirsNew)-1].CreatedAt.Before(oursNew[len(oursNew)-1].CreatedAt):
+		kept, added = theirs, oursNew
+	}
+	for _, r := range added {
+		if !policy.Allows(r.Kind) {
+			return nil, eris.Wrapf(
+				ErrMergeConflict,
+				"state: both sides created releases, %s would have to be re-linked but %s releases can not be re-hashed. publish it again on top of the other side",
+				r, r.Kind,
+			)
+		}
+	}
+	result.Releases = kept.Releases
+	c := &Checkpoint{
+		Reason:    "merge",
+		Rechained: make(map[Hash]Hash),
+	}
+	retagged := make([]string, 0)
+	for i := len(added) - 1; i >= 0; i-- {
+		r := added[i].Copy()
+		if result.hasTag(r.Tag) {
+			tag, err := result.nextFreeTag(r)
+			if err != nil {
+				return nil, err
+			}
+			retagged = append(retagged, fmt.Sprintf("%s as %s", r.Tag, tag))
+			r.Tag = tag
+		}
+		r.PreviousBlockHash = result.Releases[0].BlockHash
+		hash, err := r.Hash()
+		if err != nil {
+			return nil, err
+		}
+		c.Rechained[r.BlockHash] = hash
+		r.BlockHash = hash
+		result.Releases = append([]*Release{r}, result.Releases...)
+	}
+	if len(retagged) != 0 {
+		c.Reason = fmt.Sprintf("merge, retagged %s", strings.Join(retagged, ", "))
+	}
+	c.CreatedAt = result.now()
+	if err := result.addCheckpoint(c); err != nil {
+		return nil, err
+	}
+	if err := result.Validate(); err != nil {
+		return nil, err
+	}
+	result.emitChange(EventChainMerged, c)
+	return result, nil
+}
+
+// diverged returns the releases of both states created after their newest common release.
+// The states must share the whole chain below it.
+func diverged(ours, theirs *State) ([]*Release, []*Release, error) {
+	for i, r := range ours.Releases {
+		j := theirs.IndexOf(r.BlockHash)
+		if j < 0 {
+			continue
+		}
+		if len(ours.Releases)-i != len(theirs.Releases)-j {
+			return nil, nil, eris.Wrapf(ErrMergeConflict, "state: the chains below %s differ, one side was pruned or archived", r)
+		}
+		return ours.Releases[:i], theirs.Releases[:j], nil
+	}
+	if len(ours.Releases) == 0 || len(theirs.Releases) == 0 {
+		return ours.Releases, theirs.Releases, nil
+	}
+	return nil, nil, eris.Wrap(ErrMergeConflict, "state: the chains have no common release")
+}
+
+// sameHead reports whether both states have the same head release and checkpoint.
+func sameHead(a, b *State) bool {
+	head := func(s *State) (Hash, Hash) {
+		var r, c Hash
+		if len(s.Releases) != 0 {
+			r = s.Releases[0].BlockHash
+		}
+		if len(s.Checkpoints) != 0 {
+			c = s.Checkpoints[0].Hash
+		}
+		return r, c
+	}
+	ar, ac := head(a)
+	br, bc := head(b)
+	return ar == br && ac == bc
+}
+
+// mergeCheckpoints returns the checkpoints of both sides, newest first.
+func mergeCheckpoints(ours, theirs *State) []*Checkpoint {
+	seen := make(map[Hash]bool)
+	merged := make([]*Checkpoint, 0)
+	for _, c := range ours.Checkpoints {
+		seen[c.Hash] = true
+		merged = append(merged, c)
+	}
+	for _, c := range theirs.Checkpoints {
+		if seen[c.Hash] {
+			continue
+		}
+		merged = append(merged, c)
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].CreatedAt.After(merged[j].CreatedAt)
+	})
+	return merged
+}
+
+func (s *State) hasTag(tag string) bool {
+	for _, v := range s.Releases {
+		if v.Tag == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// nextFreeTag returns the next patch version after the latest release of the kind of r
+// that is not used by any release.
+func (s *State) nextFreeTag(r *Release) (string, error) {
+	version, err := semver.NewVersion(s.Latest(r.Kind).Tag)
+	if err != nil {
+		return "", eris.Wrapf(err, "state: could not retag %s", r)
+	}
+	prerelease := r.Kind.String()
+	if r.Kind.Is(ReleaseKindGA) {
+		prerelease = ""
+	}
+	for {
+		// IncPatch only drops the prerelease of a prerelease version
+		released, err := version.SetPrerelease("")
+		if err != nil {
+			return "", eris.Wrapf(err, "state: could not retag %s", r)
+		}
+		next, err := released.IncPatch().SetPrerelease(prerelease)
+		if err != nil {
+			return "", eris.Wrapf(err, "state: could not retag %s", r)
+		}
+		version = &next
+		tag := "v" + version.String()
+		if !s.hasTag(tag) {
+			return tag, nil
+		}
+	}
+}
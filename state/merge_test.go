@@ -0,0 +1,78 @@
+package state_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+)
+
+// fork returns a copy of the state with its own clock starting at the given time.
+func fork(g *goblin.G, s *state.State, at time.Time) *state.State {
+	b, err := s.Marshal()
+	g.Assert(err).IsNil()
+	forked := state.NewState()
+	g.Assert(forked.Load(b)).IsNil()
+	forked.Clock = &tickClock{t: at}
+	return forked
+}
+
+func TestMerge(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("Merge", func() {
+		var base, ours, theirs *state.State
+		g.BeforeEach(func() {
+			base = newTestState()
+			g.Assert(base.CreateRelease(newTestRelease(g, "v1.0.0-dev", state.VersionMap{"user-service": {Version: "3db20cf"}}))).IsNil()
+			ours = fork(g, base, time.Date(2021, 12, 21, 0, 0, 0, 0, time.UTC))
+			theirs = fork(g, base, time.Date(2021, 12, 22, 0, 0, 0, 0, time.UTC))
+		})
+		g.It("should take the changed side", func() {
+			g.Assert(theirs.CreateRelease(newTestRelease(g, "v1.0.1-dev", state.VersionMap{"user-service": {Version: "4ca603f"}}))).IsNil()
+			merged, err := state.Merge(base, ours, theirs, state.DefaultMergePolicy)
+			g.Assert(err).IsNil()
+			g.Assert(merged.Releases[0].BlockHash).Equal(theirs.Releases[0].BlockHash)
+			g.Assert(len(merged.Releases)).Equal(2)
+		})
+		g.It("should re-link the later releases onto the other side", func() {
+			g.Assert(theirs.CreateRelease(newTestRelease(g, "v1.0.1-dev", state.VersionMap{"user-service": {Version: "4ca603f"}}))).IsNil()
+			g.Assert(ours.CreateRelease(newTestRelease(g, "v1.0.1-dev", state.VersionMap{"user-service": {Version: "5f1e2a9"}}))).IsNil()
+			merged, err := state.Merge(base, ours, theirs, state.DefaultMergePolicy)
+			g.Assert(err).IsNil()
+			g.Assert(merged.Validate()).IsNil()
+			g.Assert(len(merged.Releases)).Equal(3)
+			g.Assert(merged.Releases[1].BlockHash).Equal(ours.Releases[0].BlockHash)
+			g.Assert(merged.Releases[0].Tag).Equal("v1.0.2-dev")
+			g.Assert(merged.Releases[0].Versions["user-service"].Version).Equal("4ca603f")
+			g.Assert(merged.Checkpoints[0].Rechained[theirs.Releases[0].BlockHash]).Equal(merged.Releases[0].BlockHash)
+			g.Assert(merged.Checkpoints[0].Reason).Equal("merge, retagged v1.0.1-dev as v1.0.2-dev")
+			g.Assert(len(merged.Events())).Equal(1)
+			g.Assert(merged.Events()[0].Type).Equal(state.EventChainMerged)
+			g.Assert(merged.Events()[0].Checkpoint.Hash).Equal(merged.Checkpoints[0].Hash)
+		})
+		g.It("should refuse to re-link kinds the policy does not allow", func() {
+			g.Assert(ours.CreateRelease(newTestRelease(g, "v1.0.1-dev", state.VersionMap{"user-service": {Version: "5f1e2a9"}}))).IsNil()
+			g.Assert(theirs.PromoteTo(state.ReleaseKindAlpha)).IsNil()
+			_, err := state.Merge(base, ours, theirs, state.DefaultMergePolicy)
+			g.Assert(eris.Cause(err)).Equal(state.ErrMergeConflict)
+			merged, err := state.Merge(base, ours, theirs, state.MergePolicy{
+				Rechain: []state.ReleaseKind{state.ReleaseKindDev, state.ReleaseKindAlpha},
+			})
+			g.Assert(err).IsNil()
+			g.Assert(merged.Releases[0].Tag).Equal("v1.0.0-alpha")
+		})
+		g.It("should refuse to merge rewritten chains", func() {
+			g.Assert(ours.CreateRelease(newTestRelease(g, "v1.0.1-dev", state.VersionMap{"user-service": {Version: "5f1e2a9"}}))).IsNil()
+			g.Assert(theirs.CreateRelease(newTestRelease(g, "v1.0.1-dev", state.VersionMap{"user-service": {Version: "4ca603f"}}))).IsNil()
+			g.Assert(theirs.CreateRelease(newTestRelease(g, "v1.0.2-dev", state.VersionMap{"user-service": {Version: "6a2b3c4"}}))).IsNil()
+			_, err := theirs.Prune(func(i int, r *state.Release) bool {
+				return r.Tag == "v1.0.0-dev"
+			}, "test")
+			g.Assert(err).IsNil()
+			_, err = state.Merge(base, ours, theirs, state.DefaultMergePolicy)
+			g.Assert(eris.Cause(err)).Equal(state.ErrMergeConflict)
+		})
+	})
+}
@@ -0,0 +1,106 @@
+package state
+
+import (
+	"strings"
+
+	"github.com/rotisserie/eris"
+)
+
+var (
+	ErrCaseRuleInvalid = eris.New("state: case rule is invalid")
+)
+
+// CaseRule tells how the case of a string is normalized.
+type CaseRule string
+
+const (
+	CasePreserve CaseRule = "preserve"
+	CaseLower    CaseRule = "lower"
+)
+
+// Normalization holds the rules applied to service names and versions.
+// Surrounding white spaces are always trimmed.
+type Normalization struct {
+	// Service is the case rule of service names. Defaults to lower.
+	Service CaseRule `json:"service,omitempty"`
+	// Version is the case rule of versions. Defaults to preserve,
+	// since image tags and build ids can be case sensitive.
+	Version CaseRule `json:"version,omitempty"`
+}
+
+// DefaultNormalization lower cases service names and preserves versions.
+var DefaultNormalization = Normalization{
+	Service: CaseLower,
+	Version: CasePreserve,
+}
+
+// Validate returns an error if a rule is unknown.
+func (n Normalization) Validate() error {
+	for _, rule := range []CaseRule{n.Service, n.Version} {
+		switch rule {
+		case "", CasePreserve, CaseLower:
+		default:
+			return eris.Wrapf(ErrCaseRuleInvalid, "state: unknown case rule %q", rule)
+		}
+	}
+	return nil
+}
+
+// NormalizeService returns the normalized service name.
+func (n Normalization) NormalizeService(svc string) string {
+	return applyCase(n.Service, CaseLower, svc)
+}
+
+// NormalizeVersion returns the normalized version.
+func (n Normalization) NormalizeVersion(version string) string {
+	return applyCase(n.Version, CasePreserve, version)
+}
+
+func applyCase(rule, fallback CaseRule, s string) string {
+	if rule == "" {
+		rule = fallback
+	}
+	s = strings.TrimSpace(s)
+	if rule == CaseLower {
+		return strings.ToLower(s)
+	}
+	return s
+}
+
+// NormalizeServiceVersion applies the version rule to every field of the service version.
+func (n Normalization) NormalizeServiceVersion(v ServiceVersion) ServiceVersion {
+	return v.mapFields(n.NormalizeVersion)
+}
+
+// Set creates a normalized entry in the map.
+func (n Normalization) Set(m VersionMap, svc string, v ServiceVersion) {
+	m[n.NormalizeService(svc)] = n.NormalizeServiceVersion(v)
+}
+
+// Get returns the version of the service, looked up by its normalized name.
+func (n Normalization) Get(m VersionMap, svc string) (ServiceVersion, error) {
+	v, ok := m[n.NormalizeService(svc)]
+	if v.IsZero() || !ok {
+		return ServiceVersion{}, ErrVersionNotFound
+	}
+	return v, nil
+}
+
+// Remove deletes the service, looked up by its normalized name.
+func (n Normalization) Remove(m VersionMap, svc string) {
+	delete(m, n.NormalizeService(svc))
+}
+
+// Apply returns a normalized copy of the map.
+// It returns error if two services collide after normalization with different versions.
+func (n Normalization) Apply(m VersionMap) (VersionMap, error) {
+	result := make(VersionMap, len(m))
+	for _, svc := range m.Services() {
+		name, version := n.NormalizeService(svc), n.NormalizeServiceVersion(m[svc])
+		if existing, ok := result[name]; ok && existing != version {
+			return nil, eris.Errorf("state: services collide as %q after normalization with versions %q and %q", name, existing, version)
+		}
+		result[name] = version
+	}
+	return result, nil
+}
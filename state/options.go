@@ -0,0 +1,34 @@
+package state
+
+// ReleaseOption modifies a release before it gets added to the state.
+type ReleaseOption func(r *Release) error
+
+// WithAnnotations merges the given annotations into the release.
+func WithAnnotations(a Annotations) ReleaseOption {
+	return func(r *Release) error {
+		if len(a) == 0 {
+			return nil
+		}
+		if r.Annotations == nil {
+			r.Annotations = make(Annotations, len(a))
+		}
+		for k, v := range a {
+			if k == "" {
+				return ErrAnnotationInvalid
+			}
+			r.Annotations[k] = v
+		}
+		return nil
+	}
+}
+
+// WithNotes sets the release notes of the release.
+// Empty notes leave the release untouched.
+func WithNotes(notes string) ReleaseOption {
+	return func(r *Release) error {
+		if notes != "" {
+			r.Notes = notes
+		}
+		return nil
+	}
+}
@@ -0,0 +1,43 @@
+package state
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Origin describes who or what created a release block.
+type Origin struct {
+	// Actor is the person or the bot who created the release.
+	Actor string `json:"actor,omitempty"`
+	// Pipeline identifies the CI job that created the release, if any.
+	Pipeline string `json:"pipeline,omitempty"`
+	// Hostname of the machine the release was created on.
+	Hostname string `json:"hostname,omitempty"`
+	// Version of microstate that created the release.
+	Version string `json:"version,omitempty"`
+}
+
+// IsEmpty returns true if no origin information is present.
+func (o Origin) IsEmpty() bool {
+	return o == Origin{}
+}
+
+// String returns the string representation of the origin.
+// e.g. alice via github:1234 on runner-1 (microstate v0.2.0)
+func (o Origin) String() string {
+	var b strings.Builder
+	b.WriteString(o.Actor)
+	if b.Len() == 0 {
+		b.WriteString("unknown")
+	}
+	if o.Pipeline != "" {
+		fmt.Fprintf(&b, " via %s", o.Pipeline)
+	}
+	if o.Hostname != "" {
+		fmt.Fprintf(&b, " on %s", o.Hostname)
+	}
+	if o.Version != "" {
+		fmt.Fprintf(&b, " (microstate %s)", o.Version)
+	}
+	return b.String()
+}
@@ -0,0 +1,278 @@
+package state
+
+import (
+	"encoding/json"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/rotisserie/eris"
+)
+
+var (
+	ErrVersionFormatInvalid = eris.New("state: version does not match the service version format")
+	ErrFormatTypeInvalid    = eris.New("state: version format type is invalid")
+	ErrServiceUnknown       = eris.New("state: service is not registered")
+	ErrServiceNameInvalid   = eris.New("state: service name is invalid")
+)
+
+// FormatType is the kind of version string a service uses.
+type FormatType string
+
+const (
+	// FormatSHA is a hex encoded git commit sha.
+	FormatSHA FormatType = "sha"
+	// FormatSemver is a semantic version, with or without the v prefix.
+	FormatSemver FormatType = "semver"
+	// FormatDigest is an OCI content digest. e.g. sha256:<64 hex characters>
+	FormatDigest FormatType = "digest"
+	// FormatRegex is a custom regular expression.
+	FormatRegex FormatType = "regex"
+)
+
+var (
+	shaPattern    = regexp.MustCompile(`^[0-9a-f]+$`)
+	digestPattern = regexp.MustCompile(`^[a-z0-9]+(?:[.+_-][a-z0-9]+)*:[a-zA-Z0-9=_-]{32,}$`)
+)
+
+// VersionFormat describes the expected format of a service version.
+type VersionFormat struct {
+	Type FormatType `json:"type"`
+	// Length is the exact length of a sha. Zero accepts 7 to 40 characters.
+	Length int `json:"length,omitempty"`
+	// Pattern is the regular expression of the regex type.
+	// It must match the whole version.
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// Validate returns an error if the version does not match the format.
+func (f VersionFormat) Validate(version string) error {
+	switch f.Type {
+	case FormatSHA:
+		if !shaPattern.MatchString(version) {
+			return eris.Wrapf(ErrVersionFormatInvalid, "state: %q is not a hex sha", version)
+		}
+		if f.Length != 0 && len(version) != f.Length {
+			return eris.Wrapf(ErrVersionFormatInvalid, "state: sha %q must be %d characters long", version, f.Length)
+		}
+		if f.Length == 0 && (len(version) < 7 || len(version) > 40) {
+			return eris.Wrapf(ErrVersionFormatInvalid, "state: sha %q must be 7 to 40 characters long", version)
+		}
+	case FormatSemver:
+		if _, err := semver.StrictNewVersion(strings.TrimPrefix(version, "v")); err != nil {
+			return eris.Wrapf(ErrVersionFormatInvalid, "state: %q is not a semantic version: %v", version, err)
+		}
+	case FormatDigest:
+		if !digestPattern.MatchString(version) {
+			return eris.Wrapf(ErrVersionFormatInvalid, "state: %q is not a digest", version)
+		}
+	case FormatRegex:
+		re, err := regexp.Compile("^(?:" + f.Pattern + ")$")
+		if err != nil {
+			return eris.Wrapf(ErrFormatTypeInvalid, "state: invalid pattern %q: %v", f.Pattern, err)
+		}
+		if !re.MatchString(version) {
+			return eris.Wrapf(ErrVersionFormatInvalid, "state: %q does not match %q", version, f.Pattern)
+		}
+	default:
+		return eris.Wrapf(ErrFormatTypeInvalid, "state: unknown version format %q", f.Type)
+	}
+	return nil
+}
+
+// Service holds the registry information of a service.
+type Service struct {
+	// Format of the service version. Any version is accepted if it is nil.
+	Format *VersionFormat `json:"format,omitempty"`
+	// Owners are the people or teams responsible for the service.
+	Owners []string `json:"owners,omitempty"`
+	// Repository is the source code repository url.
+	Repository string `json:"repository,omitempty"`
+	// Image is the container image repository. e.g. ghcr.io/acme/user-service
+	Image string `json:"image,omitempty"`
+}
+
+// Registry declares the known services.
+type Registry struct {
+	Services map[string]*Service `json:"services,omitempty"`
+	// Constraints declare the dependencies between service versions.
+	// e.g. gateway-service>=2.3 requires user-service>=1.8
+	Constraints []string `json:"constraints,omitempty"`
+	// Normalization overrides the DefaultNormalization rules.
+	Normalization *Normalization `json:"normalization,omitempty"`
+}
+
+// Rules returns the normalization rules of the registry.
+func (reg *Registry) Rules() Normalization {
+	if reg == nil || reg.Normalization == nil {
+		return DefaultNormalization
+	}
+	return *reg.Normalization
+}
+
+// NewRegistry returns a new and empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		Services: make(map[string]*Service),
+	}
+}
+
+// Get returns the service of the given name.
+func (reg *Registry) Get(svc string) (*Service, bool) {
+	if reg == nil {
+		return nil, false
+	}
+	s, ok := reg.Services[reg.Rules().NormalizeService(svc)]
+	return s, ok
+}
+
+// IsEmpty returns true if no service is registered.
+func (reg *Registry) IsEmpty() bool {
+	return reg == nil || len(reg.Services) == 0
+}
+
+// Names returns the sorted names of the registered services.
+func (reg *Registry) Names() []string {
+	if reg == nil {
+		return nil
+	}
+	names := make(VersionMap)
+	for k := range reg.Services {
+		names[k] = ServiceVersion{}
+	}
+	return names.Services()
+}
+
+// Add registers the service under the given name.
+// An already registered service gets replaced.
+func (reg *Registry) Add(name string, svc *Service) error {
+	name = reg.Rules().NormalizeService(name)
+	if name == "" || strings.ContainsAny(name, "@=, ") {
+		return eris.Wrapf(ErrServiceNameInvalid, "state: invalid service name %q", name)
+	}
+	if svc.Format != nil {
+		check := &Registry{Services: map[string]*Service{name: svc}}
+		if err := check.Validate(); err != nil {
+			return err
+		}
+	}
+	reg.Services[name] = svc
+	return nil
+}
+
+// Remove unregisters the service of the given name.
+func (reg *Registry) Remove(name string) error {
+	name = reg.Rules().NormalizeService(name)
+	if _, ok := reg.Services[name]; !ok {
+		return eris.Wrapf(ErrServiceUnknown, "state: service %q is not registered", name)
+	}
+	delete(reg.Services, name)
+	return nil
+}
+
+// Unknown returns the sorted services of the map that are not registered.
+// It returns nil for an empty registry, which accepts all services.
+func (reg *Registry) Unknown(m VersionMap) []string {
+	if reg.IsEmpty() {
+		return nil
+	}
+	unknown := make([]string, 0)
+	for _, svc := range m.Services() {
+		if _, ok := reg.Get(svc); !ok {
+			unknown = append(unknown, svc)
+		}
+	}
+	return unknown
+}
+
+// Missing returns the sorted registered services that are not part of the map.
+func (reg *Registry) Missing(m VersionMap) []string {
+	missing := make([]string, 0)
+	for _, name := range reg.Names() {
+		if _, ok := m[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// ValidateVersion returns an error if the primary version does not match the format of the service.
+// Services without a declared format accept any version.
+func (reg *Registry) ValidateVersion(svc string, v ServiceVersion) error {
+	if v.SHA != "" && !shaPattern.MatchString(v.SHA) {
+		return eris.Wrapf(ErrVersionFormatInvalid, "state: sha %q of %s is not a hex sha", v.SHA, svc)
+	}
+	s, ok := reg.Get(svc)
+	if !ok || s.Format == nil {
+		return nil
+	}
+	if err := s.Format.Validate(v.Primary()); err != nil {
+		return eris.Wrapf(err, "state: invalid version of %s", svc)
+	}
+	return nil
+}
+
+// ValidateVersions validates all the versions of the map.
+func (reg *Registry) ValidateVersions(m VersionMap) error {
+	for _, svc := range m.Services() {
+		if err := reg.ValidateVersion(svc, m[svc]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate returns an error if any declared format or constraint is invalid.
+func (reg *Registry) Validate() error {
+	if err := reg.Rules().Validate(); err != nil {
+		return err
+	}
+	for _, c := range reg.Constraints {
+		if _, err := ParseConstraint(c); err != nil {
+			return err
+		}
+	}
+	for name, s := range reg.Services {
+		if name != reg.Rules().NormalizeService(name) {
+			return eris.Wrapf(ErrServiceNameInvalid, "state: service name %q is not normalized", name)
+		}
+		if s == nil || s.Format == nil {
+			continue
+		}
+		switch s.Format.Type {
+		case FormatSHA, FormatSemver, FormatDigest:
+		case FormatRegex:
+			if _, err := regexp.Compile(s.Format.Pattern); err != nil {
+				return eris.Wrapf(ErrFormatTypeInvalid, "state: invalid pattern of %s: %v", name, err)
+			}
+		default:
+			return eris.Wrapf(ErrFormatTypeInvalid, "state: unknown version format %q of %s", s.Format.Type, name)
+		}
+	}
+	return nil
+}
+
+// Export exports the registry to the given filepath.
+func (reg *Registry) Export(filepath string) error {
+	b, err := json.MarshalIndent(reg, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath, b, os.ModePerm)
+}
+
+// Import imports the registry from the given filepath.
+func (reg *Registry) Import(filepath string) error {
+	b, err := os.ReadFile(filepath)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, reg); err != nil {
+		return err
+	}
+	if reg.Services == nil {
+		reg.Services = make(map[string]*Service)
+	}
+	return reg.Validate()
+}
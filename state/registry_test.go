@@ -0,0 +1,88 @@
+package state_test
+
+import (
+	"testing"
+
+	"github.com/franela/goblin"
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+)
+
+func TestVersionFormat_Validate(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("VersionFormat", func() {
+		cases := []struct {
+			format  state.VersionFormat
+			version string
+			valid   bool
+		}{
+			{state.VersionFormat{Type: state.FormatSHA, Length: 7}, "4ca603f", true},
+			{state.VersionFormat{Type: state.FormatSHA, Length: 7}, "4ca60", false},
+			{state.VersionFormat{Type: state.FormatSHA}, "main", false},
+			{state.VersionFormat{Type: state.FormatSemver}, "v1.2.3", true},
+			{state.VersionFormat{Type: state.FormatSemver}, "1.2", false},
+			{state.VersionFormat{Type: state.FormatDigest}, "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", true},
+			{state.VersionFormat{Type: state.FormatDigest}, "latest", false},
+			{state.VersionFormat{Type: state.FormatRegex, Pattern: "build-[0-9]+"}, "build-12", true},
+			{state.VersionFormat{Type: state.FormatRegex, Pattern: "build-[0-9]+"}, "build-12a", false},
+		}
+		for _, c := range cases {
+			c := c
+			g.It(string(c.format.Type)+" "+c.version, func() {
+				err := c.format.Validate(c.version)
+				if c.valid {
+					g.Assert(err).IsNil()
+				} else {
+					g.Assert(eris.Cause(err)).Equal(state.ErrVersionFormatInvalid)
+				}
+			})
+		}
+	})
+}
+
+func TestRelease_ValidateWith(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("ValidateWith", func() {
+		reg := state.NewRegistry()
+		reg.Services["user-service"] = &state.Service{
+			Format: &state.VersionFormat{Type: state.FormatSHA, Length: 7},
+		}
+		g.It("should reject a version not matching the registry", func() {
+			r, err := state.NewRelease(state.ReleaseKindDev, "v1.0.0-dev", state.VersionMap{"user-service": {Version: "4ca60"}})
+			g.Assert(err).IsNil()
+			g.Assert(eris.Cause(r.ValidateWith(reg))).Equal(state.ErrVersionFormatInvalid)
+		})
+		g.It("should accept unknown services", func() {
+			r, err := state.NewRelease(state.ReleaseKindDev, "v1.0.0-dev", state.VersionMap{"other": {Version: "anything"}})
+			g.Assert(err).IsNil()
+			g.Assert(r.ValidateWith(reg)).IsNil()
+		})
+	})
+}
+
+func TestRegistry_Services(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("Registry", func() {
+		reg := state.NewRegistry()
+		g.It("should accept every service when empty", func() {
+			g.Assert(len(reg.Unknown(state.VersionMap{"a": {Version: "1"}}))).Equal(0)
+		})
+		g.It("should register services with lower case names", func() {
+			g.Assert(reg.Add("User-Service", &state.Service{Owners: []string{"team-a"}})).IsNil()
+			g.Assert(reg.Add("gateway-service", &state.Service{})).IsNil()
+			g.Assert(reg.Names()).Equal([]string{"gateway-service", "user-service"})
+		})
+		g.It("should reject invalid names", func() {
+			g.Assert(eris.Cause(reg.Add("a@b", &state.Service{}))).Equal(state.ErrServiceNameInvalid)
+		})
+		g.It("should report unknown and missing services", func() {
+			m := state.VersionMap{"user-service": {Version: "1"}, "other": {Version: "1"}}
+			g.Assert(reg.Unknown(m)).Equal([]string{"other"})
+			g.Assert(reg.Missing(m)).Equal([]string{"gateway-service"})
+		})
+		g.It("should remove services", func() {
+			g.Assert(reg.Remove("gateway-service")).IsNil()
+			g.Assert(eris.Cause(reg.Remove("gateway-service"))).Equal(state.ErrServiceUnknown)
+		})
+	})
+}
@@ -16,6 +16,7 @@ var (
 	ErrReleaseKindInvalid  = eris.New("state: release kind is invalid")
 	ErrReleaseKindIsNotDev = eris.New("state: to create a release, kind must have to be dev")
 	ErrServiceMapInvalid   = eris.New("state: invalid service map. at least one active service is required to create a release")
+	ErrAnnotationInvalid   = eris.New("state: annotation key must not be empty")
 )
 
 // Hash is a custom type to store hash string.
@@ -58,7 +59,10 @@ type Release struct {
 	Kind              ReleaseKind `json:"kind,omitempty"`
 	Tag               string      `json:"tag,omitempty"`
 	Versions          VersionMap  `json:"versions,omitempty"`
+	Annotations       Annotations `json:"annotations,omitempty"`
+	Notes             string      `json:"notes,omitempty"`
 	CreatedAt         time.Time   `json:"created_at,omitempty"`
+	Origin            *Origin     `json:"origin,omitempty"`
 	BlockHash         Hash        `json:"block_hash,omitempty"`
 	PreviousBlockHash Hash        `json:"previous_block_hash,omitempty"`
 }
@@ -96,6 +100,36 @@ func (r Release) Validate() error {
 	if len(r.Versions) == 0 {
 		return ErrServiceMapInvalid
 	}
+	if _, ok := r.Annotations[""]; ok {
+		return ErrAnnotationInvalid
+	}
+	return nil
+}
+
+// ValidateWith validates the release and checks the service versions
+// against the formats and constraints of the registry.
+// A nil registry skips the version checks.
+func (r Release) ValidateWith(reg *Registry) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	if reg == nil {
+		return nil
+	}
+	if err := reg.ValidateVersions(r.Versions); err != nil {
+		return err
+	}
+	return reg.CheckConstraints(r.Versions)
+}
+
+// Apply runs the given options against the release.
+// It stops at the first option that returns an error.
+func (r *Release) Apply(opts ...ReleaseOption) error {
+	for _, opt := range opts {
+		if err := opt(r); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -112,14 +146,23 @@ func (r Release) String() string {
 // The copied release is safe to modify.
 func (r Release) Copy() *Release {
 	r.Versions = r.Versions.Copy()
+	r.Annotations = r.Annotations.Copy()
+	if r.Origin != nil {
+		origin := *r.Origin
+		r.Origin = &origin
+	}
 	return &r
 }
 
 // Promote the release to the next release kind.
 // It returns error if next kind is invalid.
 // It returns the promoted copy of the release.
+// Annotations and notes are dropped just like the build metadata of the tag,
+// they describe the build that created a block, not the promoted one.
 func (r Release) Promote() (*Release, error) {
 	copied := r.Copy()
+	copied.Annotations = nil
+	copied.Notes = ""
 	next, err := copied.Kind.Next()
 	if err != nil {
 		return nil, err
@@ -105,3 +105,37 @@ func TestRelease_Upgrade(t *testing.T) {
 		})
 	})
 }
+
+func TestRelease_Annotations(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("Annotations", func() {
+		g.It("should be applied to the release", func() {
+			r, err := state.NewRelease(state.ReleaseKindDev, "v1.0.0-dev", state.VersionMap{"a": {Version: "1"}})
+			g.Assert(err).IsNil()
+			g.Assert(r.Apply(state.WithAnnotations(state.Annotations{"ticket": "OPS-1"}))).IsNil()
+			g.Assert(r.Annotations["ticket"]).Equal("OPS-1")
+			g.Assert(r.Validate()).IsNil()
+		})
+		g.It("should be part of the hash", func() {
+			r, _ := state.NewRelease(state.ReleaseKindDev, "v1.0.0-dev", state.VersionMap{"a": {Version: "1"}})
+			before, err := r.Hash()
+			g.Assert(err).IsNil()
+			g.Assert(r.Apply(state.WithAnnotations(state.Annotations{"build": "42"}))).IsNil()
+			after, err := r.Hash()
+			g.Assert(err).IsNil()
+			g.Assert(before.Match(after)).IsFalse()
+		})
+		g.It("should be dropped on promotion", func() {
+			r, _ := state.NewRelease(state.ReleaseKindDev, "v1.0.0-dev", state.VersionMap{"a": {Version: "1"}})
+			r.Annotations = state.Annotations{"build": "42"}
+			promoted, err := r.Promote()
+			g.Assert(err).IsNil()
+			g.Assert(len(promoted.Annotations)).Equal(0)
+			g.Assert(r.Annotations["build"]).Equal("42")
+		})
+		g.It("should reject an empty key", func() {
+			_, _, err := state.ParseAnnotation("=value")
+			g.Assert(eris.Cause(err)).Equal(state.ErrAnnotationInvalid)
+		})
+	})
+}
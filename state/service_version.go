@@ -0,0 +1,168 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/rotisserie/eris"
+)
+
+var (
+	ErrServiceVersionInvalid = eris.New("state: service version is invalid")
+)
+
+// ServiceVersion describes what is deployed for a service.
+// A service version with only the Version field set is encoded as a plain string,
+// so ledgers written before structured entries keep their block hashes.
+type ServiceVersion struct {
+	Version string `json:"version,omitempty"`
+	// Image is the container image reference, preferably pinned by digest.
+	Image string `json:"image,omitempty"`
+	// SHA is the git commit of the service repository.
+	SHA string `json:"sha,omitempty"`
+	// Chart is the helm chart version.
+	Chart string `json:"chart,omitempty"`
+	// Config is the hash of the service configuration.
+	Config string `json:"config,omitempty"`
+}
+
+// serviceVersionFields lists the field names accepted by ParseService.
+var serviceVersionFields = []string{"version", "image", "sha", "chart", "config"}
+
+// ParseService parses a service flag value.
+// It accepts the legacy name@version form and the structured
+// name:image=...,sha=...,version=...,chart=...,config=... form.
+func ParseService(s string) (string, ServiceVersion, error) {
+	s = strings.TrimSpace(s)
+	at, colon := strings.Index(s, "@"), strings.Index(s, ":")
+	switch {
+	case at > 0 && (colon < 0 || at < colon):
+		return s[:at], ServiceVersion{Version: s[at+1:]}, nil
+	case colon > 0:
+		var v ServiceVersion
+		for _, field := range strings.Split(s[colon+1:], ",") {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 || !v.set(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])) {
+				return "", v, eris.Wrapf(
+					ErrServiceVersionInvalid,
+					"state: invalid field %q of service %q, expected one of %s", field, s[:colon], strings.Join(serviceVersionFields, ", "),
+				)
+			}
+		}
+		if v.IsZero() {
+			return "", v, eris.Wrapf(ErrServiceVersionInvalid, "state: service %q has no fields", s[:colon])
+		}
+		return s[:colon], v, nil
+	default:
+		return "", ServiceVersion{}, eris.Wrapf(ErrServiceVersionInvalid, "state: invalid service %q, expected name@version or name:field=value,...", s)
+	}
+}
+
+func (v *ServiceVersion) set(field, value string) bool {
+	switch field {
+	case "version":
+		v.Version = value
+	case "image":
+		v.Image = value
+	case "sha":
+		v.SHA = value
+	case "chart":
+		v.Chart = value
+	case "config":
+		v.Config = value
+	default:
+		return false
+	}
+	return true
+}
+
+// Fields returns the non empty fields of the service version in a stable order.
+func (v ServiceVersion) Fields() [][2]string {
+	fields := make([][2]string, 0, 5)
+	for i, value := range []string{v.Version, v.Image, v.SHA, v.Chart, v.Config} {
+		if value != "" {
+			fields = append(fields, [2]string{serviceVersionFields[i], value})
+		}
+	}
+	return fields
+}
+
+// FormatService returns the service in the format accepted by ParseService.
+func FormatService(name string, v ServiceVersion) string {
+	if v.IsLegacy() {
+		return name + "@" + v.Version
+	}
+	return name + ":" + v.String()
+}
+
+// IsZero returns true if no field is set.
+func (v ServiceVersion) IsZero() bool {
+	return v == ServiceVersion{}
+}
+
+// IsLegacy returns true if only the version field is set.
+func (v ServiceVersion) IsLegacy() bool {
+	return v == ServiceVersion{Version: v.Version}
+}
+
+// Primary returns the value identifying the deployed service.
+// It is the version, falling back to the git sha and the image.
+func (v ServiceVersion) Primary() string {
+	switch {
+	case v.Version != "":
+		return v.Version
+	case v.SHA != "":
+		return v.SHA
+	default:
+		return v.Image
+	}
+}
+
+// String returns the version for legacy entries and field=value pairs otherwise.
+func (v ServiceVersion) String() string {
+	if v.IsLegacy() {
+		return v.Version
+	}
+	pairs := make([]string, 0, 5)
+	for _, f := range v.Fields() {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", f[0], f[1]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// mapFields applies the function to every field.
+func (v ServiceVersion) mapFields(fn func(string) string) ServiceVersion {
+	return ServiceVersion{
+		Version: fn(v.Version),
+		Image:   fn(v.Image),
+		SHA:     fn(v.SHA),
+		Chart:   fn(v.Chart),
+		Config:  fn(v.Config),
+	}
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (v ServiceVersion) MarshalJSON() ([]byte, error) {
+	if v.IsLegacy() {
+		return json.Marshal(v.Version)
+	}
+	type plain ServiceVersion
+	return json.Marshal(plain(v))
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (v *ServiceVersion) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*v = ServiceVersion{Version: s}
+		return nil
+	}
+	type plain ServiceVersion
+	var p plain
+	if err := json.Unmarshal(b, &p); err != nil {
+		return eris.Wrap(ErrServiceVersionInvalid, err.Error())
+	}
+	*v = ServiceVersion(p)
+	return nil
+}
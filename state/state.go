@@ -2,38 +2,81 @@ package state
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/rotisserie/eris"
 )
 
 var (
-	ErrNoRelease = eris.New("state: no releases")
+	ErrNoRelease       = eris.New("state: no releases")
+	ErrReleaseNotFound = eris.New("state: release not found")
 )
 
 // State holds all the release operations.
 type State struct {
-	Releases []*Release `json:"releases,omitempty"`
+	Releases    []*Release    `json:"releases,omitempty"`
+	Checkpoints []*Checkpoint `json:"checkpoints,omitempty"`
+	// Origin is recorded on every release created by the state.
+	Origin Origin `json:"-"`
+	// Clock is used to timestamp new releases.
+	Clock Clock `json:"-"`
+	// Registry normalizes and validates the service versions of new releases.
+	Registry *Registry `json:"-"`
+	// CheckpointInterval is the number of releases after which a checkpoint is created.
+	// Zero disables periodic checkpoints.
+	CheckpointInterval int `json:"-"`
+
+	events []Event
 }
 
 // NewState returns a new and empty state.
 func NewState() *State {
 	return &State{
-		Releases: make([]*Release, 0),
+		Releases:           make([]*Release, 0),
+		Clock:              SystemClock{},
+		CheckpointInterval: DefaultCheckpointInterval,
+	}
+}
+
+// now returns the current time of the state clock.
+func (s *State) now() time.Time {
+	if s.Clock == nil {
+		return time.Now()
 	}
+	return s.Clock.Now()
 }
 
 // CreateRelease creates a new release from the given data.
 // It prepends the release to the state.
 func (s *State) CreateRelease(r *Release) error {
+	if err := s.createRelease(r); err != nil {
+		return err
+	}
+	s.emit(EventReleaseCreated, r, nil)
+	return nil
+}
+
+func (s *State) createRelease(r *Release) error {
 	if r == nil {
 		return eris.New("state: release is nil")
 	}
-	if err := r.Validate(); err != nil {
+	versions, err := s.Registry.Rules().Apply(r.Versions)
+	if err != nil {
+		return err
+	}
+	r.Versions = versions
+	if err := r.ValidateWith(s.Registry); err != nil {
 		return err
 	}
-	r.CreatedAt = time.Now()
+	r.CreatedAt = s.now()
+	r.Origin = nil
+	if !s.Origin.IsEmpty() {
+		origin := s.Origin
+		r.Origin = &origin
+	}
 	{
 		if len(s.Releases) != 0 {
 			r.PreviousBlockHash = s.Releases[0].BlockHash
@@ -45,43 +88,150 @@ func (s *State) CreateRelease(r *Release) error {
 		r.BlockHash = hash
 	}
 	s.Releases = append([]*Release{r}, s.Releases...)
+	if s.CheckpointInterval > 0 && s.sinceCheckpoint() >= s.CheckpointInterval {
+		if _, err := s.Checkpoint("periodic"); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 // Clean removes all the releases of given kind from the state.
-func (s *State) Clean(kind ReleaseKind) {
+// The remaining releases are re-linked, see Prune.
+func (s *State) Clean(kind ReleaseKind) error {
+	_, err := s.Prune(func(_ int, r *Release) bool {
+		return r.Kind.Is(kind)
+	}, fmt.Sprintf("clean %s", kind))
+	return err
+}
+
+// PruneFunc reports whether the release at position i of the state stack should be pruned.
+type PruneFunc func(i int, r *Release) bool
+
+// Prune removes the releases matched by the given function from the state.
+// The function is called in stack order, newest release first.
+// Releases that lost their previous block are re-linked to the next remaining one and re-hashed,
+// the rewrite is recorded as a checkpoint. It returns nil if nothing was pruned.
+func (s *State) Prune(match PruneFunc, reason string) (*Checkpoint, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	kept := make([]*Release, 0, len(s.Releases))
+	pruned := make([]Hash, 0)
 	for i, v := range s.Releases {
-		if v.Kind.Is(kind) {
-			s.Releases = append(s.Releases[:i], s.Releases[i+1:]...)
+		if match(i, v) {
+			pruned = append(pruned, v.BlockHash)
+			continue
+		}
+		kept = append(kept, v)
+	}
+	if len(pruned) == 0 {
+		return nil, nil
+	}
+	s.Releases = kept
+	rechained, err := s.relink()
+	if err != nil {
+		return nil, err
+	}
+	c := &Checkpoint{
+		CreatedAt: s.now(),
+		Reason:    reason,
+		Pruned:    pruned,
+		Rechained: rechained,
+	}
+	if err := s.addCheckpoint(c); err != nil {
+		return nil, err
+	}
+	s.emitChange(EventChainPruned, c)
+	return c, nil
+}
+
+// Rewrite applies the given function to every release, from the oldest release upwards.
+// Changed releases and the ones following them are re-hashed and re-linked,
+// the rewrite is recorded as a checkpoint. It returns nil if no release changed.
+func (s *State) Rewrite(fn func(r *Release) error, reason string) (*Checkpoint, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	for i := len(s.Releases) - 1; i >= 0; i-- {
+		if err := fn(s.Releases[i]); err != nil {
+			return nil, err
 		}
 	}
+	rechained, err := s.relink()
+	if err != nil {
+		return nil, err
+	}
+	if len(rechained) == 0 {
+		return nil, nil
+	}
+	c := &Checkpoint{
+		CreatedAt: s.now(),
+		Reason:    reason,
+		Rechained: rechained,
+	}
+	if err := s.addCheckpoint(c); err != nil {
+		return nil, err
+	}
+	s.emitChange(EventChainRewritten, c)
+	return c, nil
+}
+
+// relink links every release to the one before it and re-hashes the releases whose hash changed.
+// The oldest release keeps its previous block hash.
+// It returns the old block hashes of the re-hashed releases mapped to the new ones.
+func (s *State) relink() (map[Hash]Hash, error) {
+	rechained := make(map[Hash]Hash)
+	if len(s.Releases) == 0 {
+		return rechained, nil
+	}
+	previous := s.Releases[len(s.Releases)-1].PreviousBlockHash
+	for i := len(s.Releases) - 1; i >= 0; i-- {
+		r := s.Releases[i]
+		r.PreviousBlockHash = previous
+		hash, err := r.Hash()
+		if err != nil {
+			return nil, err
+		}
+		if !r.BlockHash.Match(hash) {
+			rechained[r.BlockHash] = hash
+			r.BlockHash = hash
+		}
+		previous = r.BlockHash
+	}
+	return rechained, nil
 }
 
 // Promote promotes the latest release of the given kind to the next kind.
-func (s *State) Promote(from ReleaseKind) error {
+// The given options are applied to the promoted release before it is created.
+func (s *State) Promote(from ReleaseKind, opts ...ReleaseOption) error {
 	f := s.Latest(from)
 	t, err := f.Promote()
 	if err != nil {
 		return eris.Wrap(err, "cli: could not promote")
 	}
-	if err := s.CreateRelease(t); err != nil {
+	if err := t.Apply(opts...); err != nil {
 		return eris.Wrap(err, "cli: could not promote")
 	}
+	if err := s.createRelease(t); err != nil {
+		return eris.Wrap(err, "cli: could not promote")
+	}
+	s.emit(EventReleasePromoted, t, f)
 	return nil
 }
 
 // PromoteTo promotes the latest release of the previous kind to the given kind.
-func (s *State) PromoteTo(to ReleaseKind) error {
+func (s *State) PromoteTo(to ReleaseKind, opts ...ReleaseOption) error {
 	from, err := to.Prev()
 	if err != nil {
 		return err
 	}
-	return s.Promote(from)
+	return s.Promote(from, opts...)
 }
 
 // Export exports the state to the given filepath.
 func (s *State) Export(filepath string) error {
-	b, err := json.MarshalIndent(s, "", "\t")
+	b, err := s.Marshal()
 	if err != nil {
 		return err
 	}
@@ -94,6 +244,25 @@ func (s *State) Import(filepath string) error {
 	if err != nil {
 		return err
 	}
+	return s.Load(b)
+}
+
+// ImportFromCheckpoint imports the state from the given filepath like LoadFromCheckpoint.
+func (s *State) ImportFromCheckpoint(filepath string) error {
+	b, err := os.ReadFile(filepath)
+	if err != nil {
+		return err
+	}
+	return s.LoadFromCheckpoint(b)
+}
+
+// Marshal encodes the state in the format of the state file.
+func (s *State) Marshal() ([]byte, error) {
+	return json.MarshalIndent(s, "", "\t")
+}
+
+// Load decodes the state from the content of a state file and validates the whole chain.
+func (s *State) Load(b []byte) error {
 	if err := json.Unmarshal(b, &s); err != nil {
 		return err
 	}
@@ -103,11 +272,67 @@ func (s *State) Import(filepath string) error {
 	return nil
 }
 
+// LoadFromCheckpoint decodes the state like Load, but only re-hashes the releases
+// down to the latest checkpoint, see ValidateFromCheckpoint.
+// The checkpoint is part of the same file, use it only where the file is trusted.
+func (s *State) LoadFromCheckpoint(b []byte) error {
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	if err := s.ValidateFromCheckpoint(); err != nil {
+		return err
+	}
+	return nil
+}
+
 // Validate validates the state.
 // It checks for block hashes and matches the previous block hashes.
 func (s *State) Validate() error {
+	if err := validateReleases(s.Releases); err != nil {
+		return err
+	}
+	return s.validateCheckpoints()
+}
+
+// ValidateFromCheckpoint re-hashes the releases created after the latest checkpoint
+// and checks the links of the whole chain. The content of the releases below the checkpoint head
+// is not re-hashed, see LatestCheckpoint. It falls back to Validate if there is no checkpoint.
+func (s *State) ValidateFromCheckpoint() error {
+	c := s.LatestCheckpoint()
+	if c == nil {
+		return s.Validate()
+	}
+	if err := validateReleases(s.Releases[:s.IndexOf(c.Head)+1]); err != nil {
+		return err
+	}
+	if err := validateLinks(s.Releases); err != nil {
+		return err
+	}
+	return s.validateCheckpoints()
+}
+
+// validateLinks checks that every release links to the block hash of the next older release,
+// without re-hashing the releases. Releases must be in stack order, newest release first.
+func validateLinks(releases []*Release) error {
+	for i, v := range releases {
+		if v.BlockHash.IsEmpty() {
+			return eris.New("state: missing block hash")
+		}
+		if i == len(releases)-1 {
+			break
+		}
+		if !v.PreviousBlockHash.Match(releases[i+1].BlockHash) {
+			return eris.Errorf("state: block %s does not link to the previous release", v.BlockHash.Short())
+		}
+	}
+	return nil
+}
+
+// validateReleases checks the block hashes of the given releases and their links.
+// Releases must be in stack order, newest release first.
+func validateReleases(releases []*Release) error {
 	var previousBlock Hash
-	for i, v := range s.Releases {
+	for i, v := range releases {
 		if err := v.Validate(); err != nil {
 			return err
 		}
@@ -125,7 +350,7 @@ func (s *State) Validate() error {
 		}
 		previousBlock = v.PreviousBlockHash
 		// only last block can have a nil previous block hash
-		if previousBlock.IsEmpty() && i != len(s.Releases)-1 {
+		if previousBlock.IsEmpty() && i != len(releases)-1 {
 			return eris.New("state: missing previous block hash")
 		}
 	}
@@ -139,7 +364,9 @@ func (s *State) Rollback() {
 	if len(s.Releases) == 0 {
 		return
 	}
+	removed := s.Releases[0]
 	s.Releases = s.Releases[1:]
+	s.emit(EventReleaseRolledBack, removed, nil)
 }
 
 // Head returns the latest release from the state stack.
@@ -166,6 +393,11 @@ func (s *State) Latest(kind ReleaseKind) *Release {
 	return blank
 }
 
+// LatestE is Latest as part of the Ledger interface, it never fails.
+func (s *State) LatestE(kind ReleaseKind) (*Release, error) {
+	return s.Latest(kind), nil
+}
+
 // GetRelease returns a shallow copy of the release of the given hash.
 func (s *State) GetRelease(hash Hash) (*Release, error) {
 	for _, v := range s.Releases {
@@ -173,5 +405,71 @@ func (s *State) GetRelease(hash Hash) (*Release, error) {
 			return v.Copy(), nil
 		}
 	}
-	return nil, eris.Errorf("state: release with hash %s not found", hash.String())
+	return nil, eris.Wrapf(ErrReleaseNotFound, "state: release with hash %s not found", hash.String())
+}
+
+// Resolve returns a shallow copy of the release referenced by the given string.
+// A reference can be a release kind (latest release of the kind),
+// a release tag or a block hash prefix.
+func (s *State) Resolve(ref string) (*Release, error) {
+	if kind, err := NewReleaseKindFromString(ref); err == nil {
+		for _, v := range s.Releases {
+			if v.Kind.Is(kind) {
+				return v.Copy(), nil
+			}
+		}
+		return nil, eris.Wrapf(ErrNoRelease, "state: no %s release found", kind)
+	}
+	for _, v := range s.Releases {
+		if v.Tag == ref {
+			return v.Copy(), nil
+		}
+	}
+	if hash, err := NewHash(ref); err == nil {
+		for _, v := range s.Releases {
+			if strings.HasPrefix(v.BlockHash.String(), hash.String()) {
+				return v.Copy(), nil
+			}
+		}
+	}
+	return nil, eris.Wrapf(ErrReleaseNotFound, "state: release %q not found", ref)
+}
+
+// IndexOf returns the position of the release with the given block hash in the state stack.
+// It returns -1 if the release does not exist.
+func (s *State) IndexOf(hash Hash) int {
+	for i, v := range s.Releases {
+		if v.BlockHash.Match(hash) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Normalize rewrites the service versions of every release with the given rules.
+// It is used to migrate ledgers created with different rules, see Rewrite.
+func (s *State) Normalize(n Normalization) (*Checkpoint, error) {
+	return s.Rewrite(func(r *Release) error {
+		versions, err := n.Apply(r.Versions)
+		if err != nil {
+			return eris.Wrapf(err, "state: could not normalize %s", r)
+		}
+		r.Versions = versions
+		return nil
+	}, "normalize")
+}
+
+// Previous returns a shallow copy of the release of the same kind created before the given one.
+// It returns nil if there is no such release.
+func (s *State) Previous(r *Release) *Release {
+	i := s.IndexOf(r.BlockHash)
+	if i < 0 {
+		return nil
+	}
+	for _, v := range s.Releases[i+1:] {
+		if v.Kind.Is(r.Kind) {
+			return v.Copy()
+		}
+	}
+	return nil
 }
@@ -0,0 +1,294 @@
+package state_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	"github.com/hsblhsn/microstate/state"
+)
+
+// tickClock is a fake clock that advances one minute on every call.
+type tickClock struct {
+	t time.Time
+}
+
+func (c *tickClock) Now() time.Time {
+	now := c.t
+	c.t = c.t.Add(time.Minute)
+	return now
+}
+
+func newTestState() *state.State {
+	s := state.NewState()
+	s.Clock = &tickClock{t: time.Date(2021, 12, 20, 0, 0, 0, 0, time.UTC)}
+	return s
+}
+
+func newTestRelease(g *goblin.G, tag string, v state.VersionMap) *state.Release {
+	r, err := state.NewRelease(state.ReleaseKindDev, tag, v)
+	g.Assert(err).IsNil()
+	return r
+}
+
+func TestState_CreateRelease(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("CreateRelease", func() {
+		s := newTestState()
+		g.It("should timestamp the release with the state clock", func() {
+			r := newTestRelease(g, "v1.0.0-dev", state.VersionMap{"user-service": {Version: "3db20cf"}})
+			g.Assert(s.CreateRelease(r)).IsNil()
+			g.Assert(r.CreatedAt.Equal(time.Date(2021, 12, 20, 0, 0, 0, 0, time.UTC))).IsTrue()
+			g.Assert(r.PreviousBlockHash.IsEmpty()).IsTrue()
+			g.Assert(r.BlockHash.String()).Equal("e84b41925ec0bff3a829b831e9d2220fa4541b03bc2b7ab6050b322371ee5544")
+		})
+		g.It("should link the release to the previous one", func() {
+			r := newTestRelease(g, "v1.0.1-dev", state.VersionMap{"user-service": {Version: "4ca603f"}})
+			g.Assert(s.CreateRelease(r)).IsNil()
+			g.Assert(r.PreviousBlockHash.String()).Equal("e84b41925ec0bff3a829b831e9d2220fa4541b03bc2b7ab6050b322371ee5544")
+			g.Assert(r.BlockHash.String()).Equal("e8204f9338a49e854da7662c63842333bfa16ba9fee180eca824b3cd7ccb0ef4")
+		})
+		g.It("should produce the same hashes on every run", func() {
+			other := newTestState()
+			g.Assert(other.CreateRelease(newTestRelease(g, "v1.0.0-dev", state.VersionMap{"user-service": {Version: "3db20cf"}}))).IsNil()
+			head, err := other.Head()
+			g.Assert(err).IsNil()
+			g.Assert(head.BlockHash.String()).Equal("e84b41925ec0bff3a829b831e9d2220fa4541b03bc2b7ab6050b322371ee5544")
+		})
+		g.It("should validate", func() {
+			g.Assert(s.Validate()).IsNil()
+		})
+		g.It("should promote with the state clock", func() {
+			g.Assert(s.PromoteTo(state.ReleaseKindAlpha)).IsNil()
+			head, err := s.Head()
+			g.Assert(err).IsNil()
+			g.Assert(head.String()).Equal("alpha@v1.0.1-alpha+4e226b1a3")
+			g.Assert(head.CreatedAt.Equal(time.Date(2021, 12, 20, 0, 2, 0, 0, time.UTC))).IsTrue()
+			g.Assert(s.Validate()).IsNil()
+		})
+		g.It("should reject a nil release", func() {
+			g.Assert(s.CreateRelease(nil)).IsNotNil()
+		})
+	})
+}
+
+func TestState_Validate(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("Validate", func() {
+		g.It("should detect a modified block", func() {
+			s := newTestState()
+			g.Assert(s.CreateRelease(newTestRelease(g, "v1.0.0-dev", state.VersionMap{"a": {Version: "1"}}))).IsNil()
+			s.Releases[0].Versions["a"] = state.ServiceVersion{Version: "2"}
+			g.Assert(s.Validate()).IsNotNil()
+		})
+		g.It("should detect a modified origin", func() {
+			s := newTestState()
+			s.Origin = state.Origin{Actor: "jane", Pipeline: "github:release/42", Hostname: "ci", Version: "v1.0.0"}
+			g.Assert(s.CreateRelease(newTestRelease(g, "v1.0.0-dev", state.VersionMap{"a": {Version: "1"}}))).IsNil()
+			g.Assert(*s.Releases[0].Origin).Equal(s.Origin)
+			g.Assert(s.Validate()).IsNil()
+
+			b, err := s.Marshal()
+			g.Assert(err).IsNil()
+			loaded := state.NewState()
+			g.Assert(loaded.Load(b)).IsNil()
+			loaded.Releases[0].Origin.Actor = "mallory"
+			g.Assert(loaded.Validate()).IsNotNil()
+			loaded.Releases[0].Origin = nil
+			g.Assert(loaded.Validate()).IsNotNil()
+		})
+		g.It("should detect a broken chain", func() {
+			s := newTestState()
+			g.Assert(s.CreateRelease(newTestRelease(g, "v1.0.0-dev", state.VersionMap{"a": {Version: "1"}}))).IsNil()
+			g.Assert(s.CreateRelease(newTestRelease(g, "v1.0.1-dev", state.VersionMap{"a": {Version: "2"}}))).IsNil()
+			g.Assert(s.CreateRelease(newTestRelease(g, "v1.0.2-dev", state.VersionMap{"a": {Version: "3"}}))).IsNil()
+			s.Releases = append(s.Releases[:1], s.Releases[2:]...)
+			g.Assert(s.Validate()).IsNotNil()
+		})
+	})
+}
+
+func TestState_Rollback(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("Rollback", func() {
+		s := newTestState()
+		g.It("should pop the head release", func() {
+			g.Assert(s.CreateRelease(newTestRelease(g, "v1.0.0-dev", state.VersionMap{"a": {Version: "1"}}))).IsNil()
+			g.Assert(s.CreateRelease(newTestRelease(g, "v1.0.1-dev", state.VersionMap{"a": {Version: "2"}}))).IsNil()
+			s.Rollback()
+			head, err := s.Head()
+			g.Assert(err).IsNil()
+			g.Assert(head.Tag).Equal("v1.0.0-dev")
+			g.Assert(s.Validate()).IsNil()
+		})
+		g.It("should leave an empty state", func() {
+			s.Rollback()
+			s.Rollback()
+			_, err := s.Head()
+			g.Assert(err).Equal(state.ErrNoRelease)
+		})
+	})
+}
+
+func TestState_Prune(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("Prune", func() {
+		build := func() *state.State {
+			s := newTestState()
+			g.Assert(s.CreateRelease(newTestRelease(g, "v1.0.0-dev", state.VersionMap{"a": {Version: "1"}}))).IsNil()
+			g.Assert(s.PromoteTo(state.ReleaseKindAlpha)).IsNil()
+			g.Assert(s.CreateRelease(newTestRelease(g, "v1.0.1-dev", state.VersionMap{"a": {Version: "2"}}))).IsNil()
+			g.Assert(s.CreateRelease(newTestRelease(g, "v1.0.2-dev", state.VersionMap{"a": {Version: "3"}}))).IsNil()
+			return s
+		}
+		g.It("should clean adjacent releases of the same kind", func() {
+			s := build()
+			g.Assert(s.Clean(state.ReleaseKindDev)).IsNil()
+			g.Assert(len(s.Releases)).Equal(1)
+			g.Assert(s.Releases[0].Kind).Equal(state.ReleaseKindAlpha)
+			g.Assert(s.Validate()).IsNil()
+		})
+		g.It("should re-link the remaining releases", func() {
+			s := build()
+			oldHead := s.Releases[0].BlockHash
+			c, err := s.Prune(func(i int, _ *state.Release) bool { return i == 1 }, "test")
+			g.Assert(err).IsNil()
+			g.Assert(len(s.Releases)).Equal(3)
+			g.Assert(s.Validate()).IsNil()
+			g.Assert(len(c.Pruned)).Equal(1)
+			g.Assert(c.Rechained[oldHead]).Equal(s.Releases[0].BlockHash)
+			g.Assert(c.Head).Equal(s.Releases[0].BlockHash)
+			g.Assert(s.Checkpoints[0]).Equal(c)
+		})
+		g.It("should not re-hash when pruning the oldest releases", func() {
+			s := build()
+			head := s.Releases[0].BlockHash
+			c, err := s.Prune(func(i int, _ *state.Release) bool { return i >= 2 }, "test")
+			g.Assert(err).IsNil()
+			g.Assert(len(c.Rechained)).Equal(0)
+			g.Assert(s.Releases[0].BlockHash).Equal(head)
+			g.Assert(s.Validate()).IsNil()
+		})
+		g.It("should detect a modified checkpoint", func() {
+			s := build()
+			_, err := s.Prune(func(i int, _ *state.Release) bool { return i == 1 }, "test")
+			g.Assert(err).IsNil()
+			s.Checkpoints[0].Reason = "changed"
+			g.Assert(s.Validate()).IsNotNil()
+		})
+		g.It("should return nil if nothing was pruned", func() {
+			s := build()
+			c, err := s.Prune(func(int, *state.Release) bool { return false }, "test")
+			g.Assert(err).IsNil()
+			g.Assert(c == nil).IsTrue()
+		})
+	})
+}
+
+func TestState_Archive(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("Archive", func() {
+		build := func() *state.State {
+			s := newTestState()
+			for _, tag := range []string{"v1.0.0-dev", "v1.0.1-dev", "v1.0.2-dev", "v1.0.3-dev"} {
+				g.Assert(s.CreateRelease(newTestRelease(g, tag, state.VersionMap{"a": {Version: tag}}))).IsNil()
+			}
+			return s
+		}
+		g.It("should move the oldest releases to the archive", func() {
+			s := build()
+			dir := t.TempDir()
+			c, err := s.Archive(dir, func(i int, _ *state.Release) bool { return i >= 2 })
+			g.Assert(err).IsNil()
+			g.Assert(len(c.Archived)).Equal(2)
+			g.Assert(c.Archives).Equal([]string{"2021.json"})
+			g.Assert(len(s.Releases)).Equal(2)
+			g.Assert(s.Validate()).IsNil()
+			g.Assert(s.ValidateWithArchive(dir)).IsNil()
+			archived, err := state.LoadArchive(dir)
+			g.Assert(err).IsNil()
+			g.Assert(archived[0].Tag).Equal("v1.0.1-dev")
+		})
+		g.It("should stop at the first release that does not match", func() {
+			s := build()
+			c, err := s.Archive(t.TempDir(), func(i int, _ *state.Release) bool { return i != 2 })
+			g.Assert(err).IsNil()
+			g.Assert(len(c.Archived)).Equal(1)
+		})
+		g.It("should never archive the head release", func() {
+			s := build()
+			_, err := s.Archive(t.TempDir(), func(int, *state.Release) bool { return true })
+			g.Assert(err).IsNil()
+			g.Assert(len(s.Releases)).Equal(1)
+			g.Assert(s.Releases[0].Tag).Equal("v1.0.3-dev")
+		})
+	})
+}
+
+func TestState_Checkpoint(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("Checkpoint", func() {
+		g.It("should be created periodically", func() {
+			s := newTestState()
+			s.CheckpointInterval = 2
+			g.Assert(s.CreateRelease(newTestRelease(g, "v1.0.0-dev", state.VersionMap{"a": {Version: "1"}}))).IsNil()
+			g.Assert(len(s.Checkpoints)).Equal(0)
+			g.Assert(s.CreateRelease(newTestRelease(g, "v1.0.1-dev", state.VersionMap{"a": {Version: "2"}}))).IsNil()
+			g.Assert(len(s.Checkpoints)).Equal(1)
+			g.Assert(s.LatestCheckpoint().Head).Equal(s.Releases[0].BlockHash)
+		})
+		g.It("should only re-hash releases after the latest checkpoint", func() {
+			s := newTestState()
+			g.Assert(s.CreateRelease(newTestRelease(g, "v1.0.0-dev", state.VersionMap{"a": {Version: "1"}}))).IsNil()
+			g.Assert(s.CreateRelease(newTestRelease(g, "v1.0.1-dev", state.VersionMap{"a": {Version: "2"}}))).IsNil()
+			_, err := s.Checkpoint("test")
+			g.Assert(err).IsNil()
+			g.Assert(s.CreateRelease(newTestRelease(g, "v1.0.2-dev", state.VersionMap{"a": {Version: "3"}}))).IsNil()
+			s.Releases[2].Versions["a"] = state.ServiceVersion{Version: "changed"}
+			g.Assert(s.ValidateFromCheckpoint()).IsNil()
+			g.Assert(s.Validate()).IsNotNil()
+			s.Releases[0].Versions["a"] = state.ServiceVersion{Version: "changed"}
+			g.Assert(s.ValidateFromCheckpoint()).IsNotNil()
+		})
+		g.It("should reject tampered releases below the checkpoint on import", func() {
+			s := newTestState()
+			g.Assert(s.CreateRelease(newTestRelease(g, "v1.0.0-dev", state.VersionMap{"a": {Version: "1"}}))).IsNil()
+			g.Assert(s.CreateRelease(newTestRelease(g, "v1.0.1-dev", state.VersionMap{"a": {Version: "2"}}))).IsNil()
+			_, err := s.Checkpoint("test")
+			g.Assert(err).IsNil()
+			s.Releases[1].Versions["a"] = state.ServiceVersion{Version: "changed"}
+			path := filepath.Join(t.TempDir(), ".state.json")
+			g.Assert(s.Export(path)).IsNil()
+			g.Assert(state.NewState().Import(path)).IsNotNil()
+			g.Assert(state.NewState().ImportFromCheckpoint(path)).IsNil()
+		})
+		g.It("should detect removed releases below the checkpoint", func() {
+			s := newTestState()
+			for _, tag := range []string{"v1.0.0-dev", "v1.0.1-dev", "v1.0.2-dev"} {
+				g.Assert(s.CreateRelease(newTestRelease(g, tag, state.VersionMap{"a": {Version: tag}}))).IsNil()
+			}
+			_, err := s.Checkpoint("test")
+			g.Assert(err).IsNil()
+			g.Assert(s.CreateRelease(newTestRelease(g, "v1.0.3-dev", state.VersionMap{"a": {Version: "4"}}))).IsNil()
+			s.Releases[2].Versions["a"] = state.ServiceVersion{Version: "changed"}
+			s.Releases = append(s.Releases[:3], s.Releases[3:][1:]...)
+			g.Assert(s.LatestCheckpoint() == nil).IsTrue()
+			g.Assert(s.ValidateFromCheckpoint()).IsNotNil()
+		})
+		g.It("should detect a broken link at the checkpoint head", func() {
+			s := newTestState()
+			for _, tag := range []string{"v1.0.0-dev", "v1.0.1-dev", "v1.0.2-dev"} {
+				g.Assert(s.CreateRelease(newTestRelease(g, tag, state.VersionMap{"a": {Version: tag}}))).IsNil()
+			}
+			_, err := s.Checkpoint("test")
+			g.Assert(err).IsNil()
+			// a forged block of the same height below the checkpoint head
+			forged := *s.Releases[1]
+			forged.BlockHash = "0123456789abcdef"
+			s.Releases[1] = &forged
+			g.Assert(s.LatestCheckpoint() != nil).IsTrue()
+			g.Assert(s.ValidateFromCheckpoint()).IsNotNil()
+		})
+	})
+}
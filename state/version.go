@@ -1,7 +1,7 @@
 package state
 
 import (
-	"strings"
+	"sort"
 
 	"github.com/rotisserie/eris"
 )
@@ -11,7 +11,7 @@ var (
 )
 
 // VersionMap maps a service and it's version.
-type VersionMap map[string]string
+type VersionMap map[string]ServiceVersion
 
 // NewVersionMap initializes and returns an empty map.
 func NewVersionMap() VersionMap {
@@ -20,25 +20,35 @@ func NewVersionMap() VersionMap {
 
 // Set creates an entry to the map.
 // It a version already exists with the given service, it will be overwritten.
-// Service and version will be smaller cases to stay consistent.
+// Service and version are normalized with the DefaultNormalization rules.
 func (m VersionMap) Set(svc, version string) {
-	svc = strings.ToLower(svc)
-	version = strings.ToLower(version)
-	m[svc] = version
+	DefaultNormalization.Set(m, svc, ServiceVersion{Version: version})
+}
+
+// Put creates a structured entry to the map.
+// It a version already exists with the given service, it will be overwritten.
+func (m VersionMap) Put(svc string, v ServiceVersion) {
+	DefaultNormalization.Set(m, svc, v)
 }
 
 // Get returns a version for the given service.
-func (m VersionMap) Get(svc string) (string, error) {
-	v, ok := m[svc]
-	if v == "" || !ok {
-		return "", ErrVersionNotFound
-	}
-	return v, nil
+func (m VersionMap) Get(svc string) (ServiceVersion, error) {
+	return DefaultNormalization.Get(m, svc)
 }
 
 // Remove a service and it's version from the map.
 func (m VersionMap) Remove(svc string) {
-	delete(m, svc)
+	DefaultNormalization.Remove(m, svc)
+}
+
+// Services returns the sorted service names of the map.
+func (m VersionMap) Services() []string {
+	services := make([]string, 0, len(m))
+	for k := range m {
+		services = append(services, k)
+	}
+	sort.Strings(services)
+	return services
 }
 
 // Copy returns a deep copy of the original map.
@@ -51,3 +61,46 @@ func (m VersionMap) Copy() VersionMap {
 	}
 	return newM
 }
+
+// VersionChange describes the change of a single service between two version maps.
+type VersionChange struct {
+	Service string `json:"service"`
+	// From is zero if the service was added.
+	From ServiceVersion `json:"from"`
+	// To is zero if the service was removed.
+	To ServiceVersion `json:"to"`
+}
+
+// IsAdded returns true if the service did not exist before.
+func (c VersionChange) IsAdded() bool {
+	return c.From.IsZero()
+}
+
+// IsRemoved returns true if the service does not exist anymore.
+func (c VersionChange) IsRemoved() bool {
+	return c.To.IsZero()
+}
+
+// Diff returns the changes required to get from the previous map to this one.
+// Changes are sorted by service name.
+func (m VersionMap) Diff(prev VersionMap) []VersionChange {
+	all := make(VersionMap)
+	for k := range prev {
+		all[k] = ServiceVersion{}
+	}
+	for k := range m {
+		all[k] = ServiceVersion{}
+	}
+	changes := make([]VersionChange, 0)
+	for _, svc := range all.Services() {
+		if m[svc] == prev[svc] {
+			continue
+		}
+		changes = append(changes, VersionChange{
+			Service: svc,
+			From:    prev[svc],
+			To:      m[svc],
+		})
+	}
+	return changes
+}
@@ -0,0 +1,101 @@
+package state_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/franela/goblin"
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+)
+
+func TestVersionMap_Diff(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("Diff", func() {
+		prev := state.VersionMap{"a": {Version: "1"}, "b": {Version: "1"}, "c": {Version: "1"}}
+		next := state.VersionMap{"a": {Version: "1"}, "b": {Version: "2"}, "d": {Version: "1"}}
+		changes := next.Diff(prev)
+		g.It("should skip unchanged services", func() {
+			g.Assert(len(changes)).Equal(3)
+		})
+		g.It("should report changed services", func() {
+			g.Assert(changes[0]).Equal(state.VersionChange{Service: "b", From: state.ServiceVersion{Version: "1"}, To: state.ServiceVersion{Version: "2"}})
+		})
+		g.It("should report removed services", func() {
+			g.Assert(changes[1].IsRemoved()).IsTrue()
+			g.Assert(changes[1].Service).Equal("c")
+		})
+		g.It("should report added services", func() {
+			g.Assert(changes[2].IsAdded()).IsTrue()
+			g.Assert(changes[2].Service).Equal("d")
+		})
+	})
+}
+
+func TestVersionMap_Normalization(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("Normalization", func() {
+		g.It("should normalize keys consistently", func() {
+			m := state.NewVersionMap()
+			m.Set("User-Service", "ABCdef1")
+			v, err := m.Get("User-Service")
+			g.Assert(err).IsNil()
+			g.Assert(v.Version).Equal("ABCdef1")
+			m.Remove("USER-SERVICE")
+			_, err = m.Get("user-service")
+			g.Assert(err).Equal(state.ErrVersionNotFound)
+		})
+		g.It("should apply configured rules", func() {
+			n := state.Normalization{Service: state.CasePreserve, Version: state.CaseLower}
+			m, err := n.Apply(state.VersionMap{"User-Service": {Version: " ABC "}})
+			g.Assert(err).IsNil()
+			g.Assert(m).Equal(state.VersionMap{"User-Service": {Version: "abc"}})
+		})
+		g.It("should reject colliding services", func() {
+			_, err := state.DefaultNormalization.Apply(state.VersionMap{"A": {Version: "1"}, "a": {Version: "2"}})
+			g.Assert(err).IsNotNil()
+		})
+		g.It("should migrate a ledger", func() {
+			s := newTestState()
+			g.Assert(s.CreateRelease(newTestRelease(g, "v1.0.0-dev", state.VersionMap{"a": {Version: "ABC"}}))).IsNil()
+			g.Assert(s.CreateRelease(newTestRelease(g, "v1.0.1-dev", state.VersionMap{"a": {Version: "DEF"}}))).IsNil()
+			c, err := s.Normalize(state.Normalization{Version: state.CaseLower})
+			g.Assert(err).IsNil()
+			g.Assert(len(c.Rechained)).Equal(2)
+			g.Assert(s.Releases[1].Versions["a"].Version).Equal("abc")
+			g.Assert(s.Validate()).IsNil()
+		})
+	})
+}
+
+func TestServiceVersion(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("ServiceVersion", func() {
+		g.It("should parse the legacy form", func() {
+			name, v, err := state.ParseService("user-service@4ca603f")
+			g.Assert(err).IsNil()
+			g.Assert(name).Equal("user-service")
+			g.Assert(v).Equal(state.ServiceVersion{Version: "4ca603f"})
+		})
+		g.It("should parse the structured form", func() {
+			name, v, err := state.ParseService("user-service:image=ghcr.io/acme/user:v1,sha=4ca603f")
+			g.Assert(err).IsNil()
+			g.Assert(name).Equal("user-service")
+			g.Assert(v).Equal(state.ServiceVersion{Image: "ghcr.io/acme/user:v1", SHA: "4ca603f"})
+			g.Assert(v.Primary()).Equal("4ca603f")
+		})
+		g.It("should reject unknown fields", func() {
+			_, _, err := state.ParseService("user-service:digest=x")
+			g.Assert(eris.Cause(err)).Equal(state.ErrServiceVersionInvalid)
+		})
+		g.It("should encode legacy entries as strings", func() {
+			b, err := json.Marshal(state.VersionMap{"a": {Version: "1"}, "b": {SHA: "4ca603f"}})
+			g.Assert(err).IsNil()
+			g.Assert(string(b)).Equal(`{"a":"1","b":{"sha":"4ca603f"}}`)
+			var m state.VersionMap
+			g.Assert(json.Unmarshal(b, &m)).IsNil()
+			g.Assert(m["a"]).Equal(state.ServiceVersion{Version: "1"})
+			g.Assert(m["b"]).Equal(state.ServiceVersion{SHA: "4ca603f"})
+		})
+	})
+}
@@ -0,0 +1,291 @@
+// Package watch follows a ledger and hands every new release to a handler.
+//
+// Delivery is at least once: the cursor, the block hash of the last handled release,
+// only moves forward after the handler returned without an error,
+// so a failed or interrupted release is handled again on the next poll.
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/hsblhsn/microstate/client"
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+)
+
+const (
+	// DefaultInterval is the delay between two polls of the source.
+	DefaultInterval = 5 * time.Second
+)
+
+var (
+	ErrTrainInvalid = eris.New("watch: train is invalid")
+)
+
+// Source loads the current ledger.
+type Source interface {
+	Load() (*state.State, error)
+}
+
+// File is a state file source.
+type File string
+
+// Load imports the state file.
+func (f File) Load() (*state.State, error) {
+	s := state.NewState()
+	if err := s.Import(string(f)); err != nil {
+		return nil, eris.Wrapf(err, "watch: could not import %s", string(f))
+	}
+	return s, nil
+}
+
+// Remote is a server source.
+type Remote struct {
+	Client *client.Client
+}
+
+// Load fetches the releases from the server.
+// The server does not expose checkpoints, so hashes of re-linked releases are not followed.
+func (r Remote) Load() (*state.State, error) {
+	releases, err := r.Client.Releases(0)
+	if err != nil {
+		return nil, eris.Wrap(err, "watch: could not fetch releases")
+	}
+	s := state.NewState()
+	s.Releases = releases
+	return s, nil
+}
+
+// Filter selects the handled releases.
+type Filter struct {
+	// Kinds of the releases. Empty matches every kind.
+	Kinds []state.ReleaseKind
+	// Train is a major or major.minor version line. e.g. v1 or 1.4. Empty matches every release.
+	Train string
+}
+
+// Validate returns an error if the train is invalid.
+func (f Filter) Validate() error {
+	_, err := parseTrain(f.Train)
+	return err
+}
+
+// Matches reports whether the release is selected by the filter.
+func (f Filter) Matches(r *state.Release) bool {
+	if len(f.Kinds) != 0 {
+		found := false
+		for _, k := range f.Kinds {
+			if r.Kind.Is(k) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	train, err := parseTrain(f.Train)
+	if err != nil || len(train) == 0 {
+		return err == nil
+	}
+	v, err := semver.NewVersion(r.Tag)
+	if err != nil {
+		return false
+	}
+	if v.Major() != train[0] {
+		return false
+	}
+	return len(train) == 1 || v.Minor() == train[1]
+}
+
+func parseTrain(s string) ([]uint64, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if s == "" {
+		return nil, nil
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) > 2 {
+		return nil, eris.Wrapf(ErrTrainInvalid, "watch: train %q must be a major or major.minor version", s)
+	}
+	train := make([]uint64, 0, len(parts))
+	for _, p := range parts {
+		n, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			return nil, eris.Wrapf(ErrTrainInvalid, "watch: train %q must be a major or major.minor version", s)
+		}
+		train = append(train, n)
+	}
+	return train, nil
+}
+
+// Cursor points to the last handled release.
+type Cursor struct {
+	Hash state.Hash `json:"hash"`
+	// Time is the creation time of the release.
+	// It finds the resume point if the release was rolled back.
+	Time time.Time `json:"time"`
+}
+
+// IsEmpty returns true if no release was handled yet.
+func (c Cursor) IsEmpty() bool {
+	return c.Hash.IsEmpty()
+}
+
+// LoadCursor reads the cursor from the given filepath.
+// It returns an empty cursor if the file does not exist.
+func LoadCursor(filepath string) (Cursor, error) {
+	var c Cursor
+	b, err := os.ReadFile(filepath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return c, eris.Wrap(err, "watch: could not read cursor")
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, eris.Wrap(err, "watch: could not decode cursor")
+	}
+	return c, nil
+}
+
+// Save writes the cursor to the given filepath.
+func (c Cursor) Save(filepath string) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	tmp := filepath + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return eris.Wrap(err, "watch: could not write cursor")
+	}
+	if err := os.Rename(tmp, filepath); err != nil {
+		return eris.Wrap(err, "watch: could not write cursor")
+	}
+	return nil
+}
+
+// Handler handles the event of a new release.
+type Handler func(e state.Event) error
+
+// Watcher polls a source and hands the events of new releases to the handler, oldest first.
+type Watcher struct {
+	Source  Source
+	Filter  Filter
+	Handler Handler
+	// Interval between two polls. Defaults to DefaultInterval.
+	Interval time.Duration
+	// Cursor is the last handled release.
+	Cursor Cursor
+	// CursorFile persists the cursor after every handled release. Empty keeps it in memory.
+	CursorFile string
+	// Replay handles the existing releases if the cursor is empty.
+	// Otherwise the first poll moves the cursor to the head without handling anything.
+	Replay bool
+	// OnError is called with the errors of Run. Run keeps polling after an error.
+	OnError func(err error)
+	started bool
+}
+
+// New returns a watcher of the source, resuming from the cursor file if it exists.
+func New(source Source, handler Handler, cursorFile string) (*Watcher, error) {
+	w := &Watcher{
+		Source:     source,
+		Handler:    handler,
+		Interval:   DefaultInterval,
+		CursorFile: cursorFile,
+	}
+	if cursorFile != "" {
+		c, err := LoadCursor(cursorFile)
+		if err != nil {
+			return nil, err
+		}
+		w.Cursor = c
+	}
+	return w, nil
+}
+
+// Poll loads the source once and handles the releases created after the cursor.
+// It returns the number of handled releases and stops at the first handler error.
+func (w *Watcher) Poll() (int, error) {
+	s, err := w.Source.Load()
+	if err != nil {
+		return 0, err
+	}
+	if w.Cursor.IsEmpty() && !w.Replay && !w.started {
+		w.started = true
+		head, err := s.Head()
+		if err != nil {
+			return 0, nil
+		}
+		return 0, w.advance(head)
+	}
+	w.started = true
+	events, err := s.Replay(w.since(s))
+	if err != nil {
+		return 0, eris.Wrap(err, "watch: could not replay releases")
+	}
+	handled := 0
+	for _, e := range events {
+		if w.Filter.Matches(e.Release) {
+			if err := w.Handler(e); err != nil {
+				return handled, eris.Wrapf(err, "watch: could not handle %s", e.Release)
+			}
+			handled++
+		}
+		if err := w.advance(e.Release); err != nil {
+			return handled, err
+		}
+	}
+	return handled, nil
+}
+
+// since returns the hash to resume from.
+// If the cursor release is gone, it resumes after the newest release created before it.
+func (w *Watcher) since(s *state.State) state.Hash {
+	if w.Cursor.IsEmpty() {
+		return ""
+	}
+	if _, err := s.Replay(w.Cursor.Hash); err == nil {
+		return w.Cursor.Hash
+	}
+	for _, r := range s.Releases {
+		if !r.CreatedAt.After(w.Cursor.Time) {
+			return r.BlockHash
+		}
+	}
+	return ""
+}
+
+func (w *Watcher) advance(r *state.Release) error {
+	w.Cursor = Cursor{Hash: r.BlockHash, Time: r.CreatedAt}
+	if w.CursorFile == "" {
+		return nil
+	}
+	return w.Cursor.Save(w.CursorFile)
+}
+
+// Run polls the source until the context is done.
+func (w *Watcher) Run(ctx context.Context) error {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := w.Poll(); err != nil && w.OnError != nil {
+			w.OnError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package watch_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	"github.com/hsblhsn/microstate/state"
+	"github.com/hsblhsn/microstate/watch"
+	"github.com/rotisserie/eris"
+)
+
+// memory is a source returning the same state on every load.
+type memory struct {
+	s *state.State
+}
+
+func (m *memory) Load() (*state.State, error) {
+	return m.s, nil
+}
+
+func newRelease(g *goblin.G, s *state.State, tag string) {
+	r, err := state.NewRelease(state.ReleaseKindDev, tag, state.VersionMap{"a": {Version: tag}})
+	g.Assert(err).IsNil()
+	g.Assert(s.CreateRelease(r)).IsNil()
+}
+
+func TestWatcher(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("Watcher", func() {
+		g.It("should start at the head without a cursor", func() {
+			src := &memory{s: state.NewState()}
+			handled := make([]string, 0)
+			w, err := watch.New(src, func(e state.Event) error {
+				handled = append(handled, e.Release.Tag)
+				return nil
+			}, "")
+			g.Assert(err).IsNil()
+			newRelease(g, src.s, "v1.0.0-dev")
+			n, err := w.Poll()
+			g.Assert(err).IsNil()
+			g.Assert(n).Equal(0)
+			newRelease(g, src.s, "v1.0.1-dev")
+			g.Assert(src.s.PromoteTo(state.ReleaseKindAlpha)).IsNil()
+			n, err = w.Poll()
+			g.Assert(err).IsNil()
+			g.Assert(n).Equal(2)
+			g.Assert(handled).Equal([]string{"v1.0.1-dev", "v1.0.1-alpha"})
+		})
+		g.It("should not skip the first release of an empty ledger", func() {
+			src := &memory{s: state.NewState()}
+			w := &watch.Watcher{Source: src, Handler: func(state.Event) error { return nil }}
+			_, err := w.Poll()
+			g.Assert(err).IsNil()
+			newRelease(g, src.s, "v1.0.0-dev")
+			n, err := w.Poll()
+			g.Assert(err).IsNil()
+			g.Assert(n).Equal(1)
+		})
+		g.It("should filter by kind and train", func() {
+			src := &memory{s: state.NewState()}
+			newRelease(g, src.s, "v1.4.0-dev")
+			g.Assert(src.s.PromoteTo(state.ReleaseKindAlpha)).IsNil()
+			newRelease(g, src.s, "v1.5.0-dev")
+			g.Assert(src.s.PromoteTo(state.ReleaseKindAlpha)).IsNil()
+			handled := make([]string, 0)
+			w := &watch.Watcher{
+				Source: src,
+				Filter: watch.Filter{Kinds: []state.ReleaseKind{state.ReleaseKindAlpha}, Train: "v1.5"},
+				Handler: func(e state.Event) error {
+					handled = append(handled, e.Release.Tag)
+					return nil
+				},
+				Replay: true,
+			}
+			_, err := w.Poll()
+			g.Assert(err).IsNil()
+			g.Assert(handled).Equal([]string{"v1.5.0-alpha"})
+			g.Assert(w.Cursor.Hash).Equal(src.s.Releases[0].BlockHash)
+			g.Assert(watch.Filter{Train: "1.x"}.Validate()).IsNotNil()
+		})
+		g.It("should handle a failed release again", func() {
+			src := &memory{s: state.NewState()}
+			newRelease(g, src.s, "v1.0.0-dev")
+			newRelease(g, src.s, "v1.0.1-dev")
+			cursor := filepath.Join(t.TempDir(), "cursor.json")
+			fail := true
+			handled := make([]string, 0)
+			handler := func(e state.Event) error {
+				if e.Release.Tag == "v1.0.1-dev" && fail {
+					return eris.New("deploy failed")
+				}
+				handled = append(handled, e.Release.Tag)
+				return nil
+			}
+			w, err := watch.New(src, handler, cursor)
+			g.Assert(err).IsNil()
+			w.Replay = true
+			_, err = w.Poll()
+			g.Assert(err).IsNotNil()
+			fail = false
+			// a restarted watcher resumes from the cursor file
+			w, err = watch.New(src, handler, cursor)
+			g.Assert(err).IsNil()
+			_, err = w.Poll()
+			g.Assert(err).IsNil()
+			g.Assert(handled).Equal([]string{"v1.0.0-dev", "v1.0.1-dev"})
+		})
+		g.It("should resume after a rolled back release", func() {
+			src := &memory{s: state.NewState()}
+			src.s.Clock = state.FixedClock(time.Date(2021, 12, 20, 0, 0, 0, 0, time.UTC))
+			newRelease(g, src.s, "v1.0.0-dev")
+			w := &watch.Watcher{Source: src, Handler: func(state.Event) error { return nil }}
+			_, err := w.Poll()
+			g.Assert(err).IsNil()
+			newRelease(g, src.s, "v1.0.1-dev")
+			_, err = w.Poll()
+			g.Assert(err).IsNil()
+			src.s.Rollback()
+			src.s.Clock = state.FixedClock(time.Date(2021, 12, 21, 0, 0, 0, 0, time.UTC))
+			newRelease(g, src.s, "v1.0.2-dev")
+			n, err := w.Poll()
+			g.Assert(err).IsNil()
+			g.Assert(n).Equal(1)
+		})
+		g.It("should stop when the context is done", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			w := &watch.Watcher{Source: &memory{s: state.NewState()}, Handler: func(state.Event) error { return nil }}
+			g.Assert(w.Run(ctx)).IsNil()
+		})
+	})
+}
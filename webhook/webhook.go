@@ -0,0 +1,244 @@
+// Package webhook delivers ledger events to http endpoints.
+//
+// Every delivery is a POST of the json encoded state.Event with the headers
+//
+//	X-Microstate-Event      the event type, e.g. release.promoted
+//	X-Microstate-Delivery   the delivery id, the same for every attempt and endpoint
+//	X-Microstate-Signature  sha256=<hex HMAC-SHA256 of the body>, if the endpoint has a secret
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hsblhsn/microstate/state"
+	"github.com/rotisserie/eris"
+)
+
+const (
+	// DefaultRetries is the number of times a failed delivery is retried.
+	DefaultRetries = 3
+	// DefaultBackoff is the delay before the first retry, it doubles on every retry.
+	DefaultBackoff = time.Second
+	// DefaultTimeout is the timeout of a delivery attempt.
+	DefaultTimeout = 10 * time.Second
+)
+
+var (
+	ErrDeliveryFailed = eris.New("webhook: delivery failed")
+)
+
+// Endpoint is a webhook receiver.
+type Endpoint struct {
+	URL string `json:"url"`
+	// Secret signs the deliveries.
+	Secret string `json:"secret,omitempty"`
+	// SecretEnv names the environment variable holding the secret.
+	SecretEnv string `json:"secret_env,omitempty"`
+	// Events filters the delivered events. Empty delivers every event.
+	Events []state.EventType `json:"events,omitempty"`
+}
+
+// Accepts reports whether the endpoint receives events of the given type.
+func (e Endpoint) Accepts(t state.EventType) bool {
+	if len(e.Events) == 0 {
+		return true
+	}
+	for _, v := range e.Events {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+func (e Endpoint) secret() string {
+	if e.Secret != "" {
+		return e.Secret
+	}
+	if e.SecretEnv != "" {
+		return os.Getenv(e.SecretEnv)
+	}
+	return ""
+}
+
+// Delivery is an entry of the delivery log.
+type Delivery struct {
+	ID       string          `json:"id"`
+	Event    state.EventType `json:"event"`
+	Release  state.Hash      `json:"release,omitempty"`
+	URL      string          `json:"url"`
+	Time     time.Time       `json:"time"`
+	Attempts int             `json:"attempts"`
+	Status   int             `json:"status,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// OK reports whether the endpoint accepted the delivery.
+func (d Delivery) OK() bool {
+	return d.Error == ""
+}
+
+// Dispatcher delivers events to endpoints.
+type Dispatcher struct {
+	Endpoints []Endpoint
+	HTTP      *http.Client
+	// Retries is the number of times a failed delivery is retried.
+	Retries int
+	// Backoff is the delay before the first retry.
+	Backoff time.Duration
+	// Log is the path of the delivery log, a file of json lines. Empty disables the log.
+	Log string
+
+	mu sync.Mutex
+}
+
+// NewDispatcher returns a dispatcher with the default retries.
+func NewDispatcher(endpoints []Endpoint) *Dispatcher {
+	return &Dispatcher{
+		Endpoints: endpoints,
+		HTTP:      &http.Client{Timeout: DefaultTimeout},
+		Retries:   DefaultRetries,
+		Backoff:   DefaultBackoff,
+	}
+}
+
+// Dispatch delivers the events to every endpoint accepting them and logs the deliveries.
+// A failing endpoint does not stop the other deliveries.
+func (d *Dispatcher) Dispatch(events []state.Event) ([]Delivery, error) {
+	deliveries := make([]Delivery, 0)
+	failed := make([]string, 0)
+	for _, e := range events {
+		body, err := json.Marshal(&e)
+		if err != nil {
+			return deliveries, err
+		}
+		for _, endpoint := range d.Endpoints {
+			if !endpoint.Accepts(e.Type) {
+				continue
+			}
+			delivery := d.deliver(endpoint, e, body)
+			if err := d.log(delivery); err != nil {
+				return deliveries, err
+			}
+			if !delivery.OK() {
+				failed = append(failed, fmt.Sprintf("%s to %s: %s", e.Type, endpoint.URL, delivery.Error))
+			}
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	if len(failed) != 0 {
+		return deliveries, eris.Wrap(ErrDeliveryFailed, strings.Join(failed, "; "))
+	}
+	return deliveries, nil
+}
+
+// DeliveryID returns the id of the event deliveries.
+// It identifies the event, receivers can use it to drop duplicates.
+func DeliveryID(e state.Event) string {
+	h := sha256.Sum256([]byte(e.Key()))
+	return hex.EncodeToString(h[:8])
+}
+
+// Sign returns the signature header value of the body.
+func Sign(secret string, body []byte) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write(body)
+	return "sha256=" + hex.EncodeToString(m.Sum(nil))
+}
+
+// Verify reports whether the signature header value matches the body.
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// deliver posts the event to the endpoint, retrying with exponential backoff.
+func (d *Dispatcher) deliver(endpoint Endpoint, e state.Event, body []byte) Delivery {
+	delivery := Delivery{
+		ID:    DeliveryID(e),
+		Event: e.Type,
+		URL:   endpoint.URL,
+	}
+	if e.Release != nil {
+		delivery.Release = e.Release.BlockHash
+	}
+	backoff := d.Backoff
+	for {
+		delivery.Attempts++
+		delivery.Time = time.Now()
+		delivery.Status, delivery.Error = d.post(endpoint, e, delivery.ID, body)
+		if delivery.OK() || delivery.Attempts > d.Retries || !retryable(delivery.Status) {
+			return delivery
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// retryable reports whether a delivery with the given response status may succeed later.
+// Zero is a failure without response.
+func retryable(status int) bool {
+	if status >= 400 && status < 500 {
+		return status == http.StatusRequestTimeout || status == http.StatusTooManyRequests
+	}
+	return true
+}
+
+// post sends a single delivery attempt. It returns the response status and an error message.
+func (d *Dispatcher) post(endpoint Endpoint, e state.Event, id string, body []byte) (int, string) {
+	req, err := http.NewRequest(http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err.Error()
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "microstate-webhook")
+	req.Header.Set("X-Microstate-Event", string(e.Type))
+	req.Header.Set("X-Microstate-Delivery", id)
+	if secret := endpoint.secret(); secret != "" {
+		req.Header.Set("X-Microstate-Signature", Sign(secret, body))
+	}
+	client := d.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err.Error()
+	}
+	res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, res.Status
+	}
+	return res.StatusCode, ""
+}
+
+// log appends the delivery to the delivery log.
+func (d *Dispatcher) log(delivery Delivery) error {
+	if d.Log == "" {
+		return nil
+	}
+	b, err := json.Marshal(delivery)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	f, err := os.OpenFile(d.Log, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return eris.Wrap(err, "webhook: could not open delivery log")
+	}
+	defer f.Close()
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		return eris.Wrap(err, "webhook: could not write delivery log")
+	}
+	return nil
+}
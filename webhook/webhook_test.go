@@ -0,0 +1,102 @@
+package webhook_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	"github.com/hsblhsn/microstate/state"
+	"github.com/hsblhsn/microstate/webhook"
+	"github.com/rotisserie/eris"
+)
+
+// receiver records the deliveries it accepts and fails the first requests.
+type receiver struct {
+	mu       sync.Mutex
+	failures int
+	bodies   [][]byte
+	headers  []http.Header
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.failures > 0 {
+		rc.failures--
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	b, _ := io.ReadAll(r.Body)
+	rc.bodies = append(rc.bodies, b)
+	rc.headers = append(rc.headers, r.Header.Clone())
+}
+
+func newEvents(g *goblin.G) []state.Event {
+	s := state.NewState()
+	s.Clock = state.FixedClock(time.Date(2021, 12, 20, 0, 0, 0, 0, time.UTC))
+	r, err := state.NewRelease(state.ReleaseKindDev, "v1.0.0-dev", state.VersionMap{"user-service": {Version: "3db20cf"}})
+	g.Assert(err).IsNil()
+	g.Assert(s.CreateRelease(r)).IsNil()
+	g.Assert(s.Promote(state.ReleaseKindDev)).IsNil()
+	return s.Events()
+}
+
+func TestDispatcher(t *testing.T) {
+	g := goblin.Goblin(t)
+	g.Describe("Dispatcher", func() {
+		g.It("should deliver signed events and log the deliveries", func() {
+			rc := &receiver{failures: 1}
+			ts := httptest.NewServer(rc)
+			defer ts.Close()
+			d := webhook.NewDispatcher([]webhook.Endpoint{
+				{URL: ts.URL, Secret: "secret", Events: []state.EventType{state.EventReleasePromoted}},
+			})
+			d.Backoff = time.Millisecond
+			d.Log = filepath.Join(t.TempDir(), "deliveries.log")
+			deliveries, err := d.Dispatch(newEvents(g))
+			g.Assert(err).IsNil()
+			g.Assert(len(deliveries)).Equal(1)
+			g.Assert(deliveries[0].Attempts).Equal(2)
+
+			g.Assert(len(rc.bodies)).Equal(1)
+			g.Assert(rc.headers[0].Get("X-Microstate-Event")).Equal("release.promoted")
+			g.Assert(webhook.Verify("secret", rc.bodies[0], rc.headers[0].Get("X-Microstate-Signature"))).IsTrue()
+			e := new(state.Event)
+			g.Assert(json.Unmarshal(rc.bodies[0], e)).IsNil()
+			g.Assert(e.Release.Kind).Equal(state.ReleaseKindAlpha)
+			g.Assert(e.From.Kind).Equal(state.ReleaseKindDev)
+
+			f, err := os.Open(d.Log)
+			g.Assert(err).IsNil()
+			defer f.Close()
+			lines := 0
+			for scanner := bufio.NewScanner(f); scanner.Scan(); lines++ {
+				logged := new(webhook.Delivery)
+				g.Assert(json.Unmarshal(scanner.Bytes(), logged)).IsNil()
+				g.Assert(logged.ID).Equal(deliveries[0].ID)
+			}
+			g.Assert(lines).Equal(1)
+		})
+		g.It("should report failed deliveries", func() {
+			rc := &receiver{failures: 10}
+			ts := httptest.NewServer(rc)
+			defer ts.Close()
+			d := webhook.NewDispatcher([]webhook.Endpoint{{URL: ts.URL}})
+			d.Backoff = time.Millisecond
+			d.Retries = 2
+			deliveries, err := d.Dispatch(newEvents(g))
+			g.Assert(eris.Cause(err)).Equal(webhook.ErrDeliveryFailed)
+			g.Assert(len(deliveries)).Equal(2)
+			g.Assert(deliveries[0].Attempts).Equal(3)
+			g.Assert(deliveries[0].Status).Equal(http.StatusBadGateway)
+		})
+	})
+}